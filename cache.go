@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/alexvanin/monza/chain"
+	"github.com/schollz/progressbar/v3"
+	"github.com/urfave/cli/v2"
+)
+
+// gzipMagic is the header of gzip files.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func cacheList(c *cli.Context) error {
+	dir, err := parseCacheDir(c)
+	if err != nil {
+		return err
+	}
+
+	caches, err := chain.Caches(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(caches) == 0 {
+		fmt.Printf("no caches in %s\n", dir)
+		return nil
+	}
+
+	for _, info := range caches {
+		PrintCacheInfo(info)
+	}
+
+	return nil
+}
+
+func cacheDelete(c *cli.Context) error {
+	dbPath, err := cachePath(c)
+	if err != nil {
+		return err
+	}
+
+	if !c.IsSet(fromFlagKey) && !c.IsSet(toFlagKey) {
+		err = chain.DeleteCache(dbPath)
+		if err != nil {
+			return fmt.Errorf("cannot remove cache: %w", err)
+		}
+		fmt.Printf("removed %s\n", dbPath)
+		return nil
+	}
+
+	from, to, err := parseCacheInterval(c)
+	if err != nil {
+		return err
+	}
+
+	blocks, logs, err := chain.DeleteBlocks(dbPath, from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed blocks:%d logs:%d\n", blocks, logs)
+	return nil
+}
+
+func cacheCompact(c *cli.Context) error {
+	dbPath, err := cachePath(c)
+	if err != nil {
+		return err
+	}
+
+	before, after, err := chain.Compact(dbPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("compacted %s size:%s -> %s\n", dbPath, formatSize(before), formatSize(after))
+	return nil
+}
+
+func cacheCompress(c *cli.Context) error {
+	dbPath, err := cachePath(c)
+	if err != nil {
+		return err
+	}
+
+	var progress chain.MigrationProgress
+	if !c.Bool(disableProgressBarFlagKey) {
+		progress = newMigrationProgress("encoding")
+	}
+
+	err = chain.SetCompression(dbPath, !c.Bool(disableFlagKey), progress)
+	if err != nil {
+		return err
+	}
+
+	before, after, err := chain.Compact(dbPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("encoded %s size:%s -> %s\n", dbPath, formatSize(before), formatSize(after))
+	return nil
+}
+
+func cacheIndex(c *cli.Context) error {
+	dbPath, err := cachePath(c)
+	if err != nil {
+		return err
+	}
+
+	var progress chain.MigrationProgress
+	if !c.Bool(disableProgressBarFlagKey) {
+		progress = newMigrationProgress("building")
+	}
+
+	err = chain.RebuildIndex(dbPath, progress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("rebuilt notification index of %s\n", dbPath)
+	return nil
+}
+
+func cacheRanges(c *cli.Context) error {
+	dbPath, err := cachePath(c)
+	if err != nil {
+		return err
+	}
+
+	ranges, err := chain.Ranges(dbPath)
+	if err != nil {
+		return err
+	}
+
+	incomplete, err := chain.Incomplete(dbPath)
+	if err != nil {
+		return err
+	}
+
+	headers, err := chain.HeaderRanges(dbPath)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranges {
+		PrintRange("cached", r)
+	}
+
+	for _, r := range incomplete {
+		PrintRange("nologs", r)
+	}
+
+	for _, r := range headers {
+		PrintRange("headers", r)
+	}
+
+	return nil
+}
+
+func cacheFill(c *cli.Context) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// parse blockchain info, there is no point to work without the cache
+	opts := chainOptions(c)
+	opts.NoCacheOnLock = false
+
+	blockchain, err := openChain(ctx, c, opts)
+	if err != nil {
+		return err
+	}
+	defer closeChain(blockchain)
+
+	// parse block indices
+	from, to, err := parseInterval(ctx, c.String(fromFlagKey), c.String(toFlagKey), blockchain)
+	if err != nil {
+		return err
+	}
+
+	missing, err := blockchain.Missing(from, to)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) == 0 {
+		fmt.Println("nothing to fill, all blocks are cached")
+		return nil
+	}
+
+	err = fetchBlocks(ctx, &params{
+		from:       from,
+		to:         to,
+		blockchain: blockchain,
+		workers:    blockchain.Workers(),
+		disableBar: c.Bool(disableProgressBarFlagKey),
+	}, missing)
+	if err != nil {
+		return err
+	}
+
+	// buffered blocks are written before the result is reported
+	if err = blockchain.FinishBulk(); err != nil {
+		return err
+	}
+
+	fmt.Printf("filled blocks:%d\n", len(missing))
+	return nil
+}
+
+func cacheVerify(c *cli.Context) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// parse blockchain info, there is no point to work without the cache
+	opts := chainOptions(c)
+	opts.NoCacheOnLock = false
+
+	blockchain, err := openChain(ctx, c, opts)
+	if err != nil {
+		return err
+	}
+	defer closeChain(blockchain)
+
+	// parse block indices
+	from, to, err := parseInterval(ctx, c.String(fromFlagKey), c.String(toFlagKey), blockchain)
+	if err != nil {
+		return err
+	}
+
+	var bar *progressbar.ProgressBar
+	if !c.Bool(disableProgressBarFlagKey) {
+		bar = newProgressBar(int(to-from), "verifying", "blocks")
+	}
+
+	var valid, invalid int
+	err = blockchain.Verify(ctx, from, to, func(index uint32, err error) {
+		if err != nil {
+			invalid++
+			fmt.Printf("block:%d removed [%s]\n", index, err)
+		} else {
+			valid++
+		}
+		if bar != nil {
+			_ = bar.Set(int(index - from + 1))
+		}
+	})
+	if bar != nil {
+		_ = bar.Finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("verified blocks:%d invalid:%d\n", valid, invalid)
+	return nil
+}
+
+func cacheExport(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("specify exactly one output file")
+	}
+
+	dbPath, err := cachePath(c)
+	if err != nil {
+		return err
+	}
+
+	from, to, err := parseCacheInterval(c)
+	if err != nil {
+		return err
+	}
+
+	// dumps of caches without genesis start with the first cached block
+	if !c.IsSet(fromFlagKey) {
+		ranges, err := chain.Ranges(dbPath)
+		if err != nil {
+			return err
+		}
+		if len(ranges) != 0 && ranges[0].First < to {
+			from = ranges[0].First
+		}
+	}
+
+	out := c.Args().First()
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("cannot create export file: %w", err)
+	}
+
+	progress, finish := newBlockProgress(c, "exporting")
+
+	var blocks, logs int
+	if c.Bool(archiveFlagKey) {
+		blocks, logs, err = chain.ExportArchive(dbPath, f, from, to, progress)
+	} else {
+		blocks, err = chain.ExportDump(dbPath, f, from, to, progress)
+	}
+	finish()
+
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("cannot write export file: %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(out)
+		return err
+	}
+
+	if c.Bool(archiveFlagKey) {
+		fmt.Printf("exported blocks:%d logs:%d to %s\n", blocks, logs, out)
+	} else {
+		fmt.Printf("exported blocks:%d to %s\n", blocks, out)
+	}
+	return nil
+}
+
+func cacheImport(c *cli.Context) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("specify exactly one dump or archive file")
+	}
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return fmt.Errorf("cannot open import file: %w", err)
+	}
+	defer f.Close()
+
+	// cache archives are compressed with gzip, neo-go dumps are not
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(2); bytes.Equal(magic, gzipMagic) {
+		return importArchive(c, r)
+	}
+
+	// dumps do not contain chain identity, it is received from RPC node
+	if len(c.StringSlice(endpointFlagKey)) == 0 {
+		return errors.New("rpc endpoint is required to import neo-go chain dump, use -r flag")
+	}
+
+	// parse blockchain info, there is no point to work without the cache
+	opts := chainOptions(c)
+	opts.NoCacheOnLock = false
+
+	blockchain, err := openChain(ctx, c, opts)
+	if err != nil {
+		return err
+	}
+	defer closeChain(blockchain)
+
+	err = blockchain.StartBulk(false)
+	if err != nil {
+		return err
+	}
+
+	progress, finish := newBlockProgress(c, "importing")
+	imported, skipped, err := blockchain.Import(ctx, r, progress)
+	finish()
+
+	// blocks imported before the failure are valid, so they are kept
+	if finishErr := blockchain.FinishBulk(); err == nil {
+		err = finishErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported blocks:%d skipped:%d\n", imported, skipped)
+	return nil
+}
+
+// importArchive merges the cache archive into the cache of its network,
+// RPC node is not needed, archive contains chain identity.
+func importArchive(c *cli.Context, r io.Reader) error {
+	dir, err := parseCacheDir(c)
+	if err != nil {
+		return err
+	}
+
+	progress, finish := newBlockProgress(c, "importing")
+	dbPath, blocks, logs, err := chain.ImportArchive(dir, r, progress)
+	finish()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported blocks:%d logs:%d to %s\n", blocks, logs, dbPath)
+	return nil
+}
+
+// newBlockProgress returns progress handler showing progress bar of
+// processed blocks unless it is disabled. Finish completes the bar.
+func newBlockProgress(c *cli.Context, description string) (progress func(done, total int), finish func()) {
+	if c.Bool(disableProgressBarFlagKey) {
+		return nil, func() {}
+	}
+
+	var bar *progressbar.ProgressBar
+	progress = func(done, total int) {
+		if bar == nil {
+			bar = newProgressBar(total, description, "blocks")
+		}
+		_ = bar.Set(done)
+	}
+
+	finish = func() {
+		if bar != nil {
+			_ = bar.Finish()
+		}
+	}
+
+	return progress, finish
+}
+
+func PrintRange(kind string, r chain.Range) {
+	fmt.Printf("%s:%d-%d blocks:%d\n", kind, r.First, r.Last, r.Last-r.First+1)
+}
+
+func PrintCacheInfo(info chain.CacheInfo) {
+	if info.Locked {
+		fmt.Printf("network:%d size:%s locked path:%s\n", info.Magic, formatSize(info.Size), info.Path)
+		return
+	}
+
+	s := fmt.Sprintf("network:%d size:%s blocks:%d",
+		info.Magic, formatSize(info.Size), info.Blocks,
+	)
+
+	if info.Blocks != 0 {
+		s += fmt.Sprintf(" min:%d max:%d", info.MinBlock, info.MaxBlock)
+	}
+
+	s += fmt.Sprintf(" logs:%d", info.Logs)
+
+	if info.Headers != 0 {
+		s += fmt.Sprintf(" headers:%d", info.Headers)
+	}
+
+	s += fmt.Sprintf(" schema:%d", info.Version)
+
+	if info.Identity != nil {
+		s += fmt.Sprintf(" genesis:%s", info.Identity.Genesis.StringLE())
+	}
+
+	s += fmt.Sprintf(" path:%s", info.Path)
+
+	fmt.Println(s)
+}
+
+// cachePath returns path to the existing cache of the network specified
+// in command flags.
+func cachePath(c *cli.Context) (string, error) {
+	dir, err := parseCacheDir(c)
+	if err != nil {
+		return "", err
+	}
+
+	if !c.IsSet(networkFlagKey) {
+		return "", errors.New("network magic is not specified, use -m flag")
+	}
+
+	magic := c.Uint64(networkFlagKey)
+	if magic > math.MaxUint32 {
+		return "", fmt.Errorf("invalid network magic %d", magic)
+	}
+
+	dbPath := chain.CachePath(dir, uint32(magic))
+	if _, err = os.Stat(dbPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("there is no cache for network %d in %s", magic, dir)
+		}
+		return "", fmt.Errorf("cannot stat cache: %w", err)
+	}
+
+	return dbPath, nil
+}
+
+// offlineCachePath returns path to the cache for offline mode. The cache is
+// selected by the network magic, cache flag pointing to the file or it is the
+// only cache in the cache dir.
+func offlineCachePath(c *cli.Context) (string, error) {
+	if fi, err := os.Stat(c.String(cacheFlagKey)); err == nil && fi.Mode().IsRegular() {
+		return c.String(cacheFlagKey), nil
+	}
+
+	if c.IsSet(networkFlagKey) {
+		return cachePath(c)
+	}
+
+	dir, err := parseCacheDir(c)
+	if err != nil {
+		return "", err
+	}
+
+	caches, err := chain.Caches(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(caches) != 1 {
+		return "", fmt.Errorf("%d caches found in %s, select one with -m flag", len(caches), dir)
+	}
+
+	return caches[0].Path, nil
+}
+
+// parseCacheInterval returns absolute block interval of cache commands.
+// Omitted values cover the whole cache.
+func parseCacheInterval(c *cli.Context) (from, to uint32, err error) {
+	fromV, toV := c.Uint64(fromFlagKey), uint64(math.MaxUint32)
+	if c.IsSet(toFlagKey) {
+		toV = c.Uint64(toFlagKey)
+	}
+
+	if toV > math.MaxUint32 || fromV >= toV {
+		return 0, 0, ErrInvalidInterval(strconv.FormatUint(fromV, 10), strconv.FormatUint(toV, 10))
+	}
+
+	return uint32(fromV), uint32(toV), nil
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
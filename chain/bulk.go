@@ -0,0 +1,264 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// bulkBufferSize is the size of encoded blocks and application logs
+// buffered in bulk ingest mode before they are written to the cache.
+const bulkBufferSize = 16 << 20
+
+// bulk buffers blocks, headers, application logs and notification index
+// records in bulk ingest mode. Buffered data is returned by the chain the
+// same way as cached one.
+type bulk struct {
+	store *boltStorage
+
+	mu      sync.Mutex
+	size    int
+	blocks  map[uint32]bulkBlock
+	headers map[uint32]bulkHeader
+	logs    map[util.Uint256]bulkLog
+	index   []bulkIndex
+}
+
+type bulkBlock struct {
+	block *block.Block
+	data  []byte
+}
+
+type bulkHeader struct {
+	header *block.Header
+	data   []byte
+}
+
+type bulkLog struct {
+	log  *result.ApplicationLog
+	data []byte
+}
+
+type bulkIndex struct {
+	block *block.Block
+	logs  []*result.ApplicationLog
+}
+
+// StartBulk switches the chain to bulk ingest mode. Fetched blocks, headers,
+// application logs and notification index records are buffered and written
+// to the cache in large transactions. If noSync is set, the cache file is
+// synced only when bulk ingest mode is finished. It is faster, but a system
+// crash before that may corrupt the cache.
+//
+// StartBulk and FinishBulk must not be called concurrently with other
+// methods of the chain.
+func (d *Chain) StartBulk(noSync bool) error {
+	if d.db == nil || d.db.IsReadOnly() {
+		return errors.New("bulk ingest mode requires writable cache")
+	}
+
+	if d.bulk != nil {
+		return errors.New("bulk ingest mode is already started")
+	}
+
+	d.db.NoSync = noSync
+	d.bulk = &bulk{
+		store:   d.store.(*boltStorage),
+		blocks:  make(map[uint32]bulkBlock),
+		headers: make(map[uint32]bulkHeader),
+		logs:    make(map[util.Uint256]bulkLog),
+	}
+
+	return nil
+}
+
+// FinishBulk writes buffered data to the cache, syncs the cache file and
+// returns the chain to the normal mode. Close finishes bulk ingest mode as
+// well, so buffered data is not lost if processing is interrupted.
+func (d *Chain) FinishBulk() error {
+	if d.bulk == nil {
+		return nil
+	}
+
+	d.bulk.mu.Lock()
+	err := d.bulk.flush()
+	d.bulk.mu.Unlock()
+	d.bulk = nil
+
+	if d.db.NoSync {
+		d.db.NoSync = false
+		if syncErr := d.db.Sync(); err == nil && syncErr != nil {
+			err = fmt.Errorf("cannot sync cache: %w", syncErr)
+		}
+	}
+
+	return err
+}
+
+func (u *bulk) block(index uint32) *block.Block {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.blocks[index].block
+}
+
+func (u *bulk) putBlock(b *block.Block) error {
+	data, err := encodeBlock(b, u.store.compress)
+	if err != nil {
+		return err
+	}
+
+	// hashes are computed before the block is shared with other goroutines
+	_ = containers(b)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.blocks[b.Index] = bulkBlock{block: b, data: data}
+
+	return u.grow(len(data))
+}
+
+func (u *bulk) header(index uint32) *block.Header {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.headers[index].header
+}
+
+func (u *bulk) putHeader(h *block.Header) error {
+	data, err := encodeHeader(h, u.store.compress)
+	if err != nil {
+		return err
+	}
+
+	// hash is computed before the header is shared with other goroutines
+	_ = h.Hash()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.headers[h.Index] = bulkHeader{header: h, data: data}
+
+	return u.grow(len(data))
+}
+
+func (u *bulk) applicationLog(h util.Uint256) *result.ApplicationLog {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.logs[h].log
+}
+
+func (u *bulk) putApplicationLog(h util.Uint256, appLog *result.ApplicationLog) error {
+	data, err := encodeLog(appLog, u.store.compress)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.logs[h] = bulkLog{log: appLog, data: data}
+
+	return u.grow(len(data))
+}
+
+func (u *bulk) putIndex(b *block.Block, logs []*result.ApplicationLog) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.index = append(u.index, bulkIndex{block: b, logs: logs})
+}
+
+// grow adds the size of buffered record and flushes the buffer when it is
+// full. It is called with the lock held.
+func (u *bulk) grow(size int) error {
+	u.size += size
+	if u.size < bulkBufferSize {
+		return nil
+	}
+
+	return u.flush()
+}
+
+// flush writes buffered data to the cache in a single transaction. It is
+// called with the lock held.
+func (u *bulk) flush() error {
+	if u.size == 0 && len(u.index) == 0 {
+		return nil
+	}
+
+	// records are inserted in the order of keys, so bbolt touches less
+	// pages, keys of both buckets are little endian
+	blocks := make([]bulkBlock, 0, len(u.blocks))
+	for _, b := range u.blocks {
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return bits.ReverseBytes32(blocks[i].block.Index) < bits.ReverseBytes32(blocks[j].block.Index)
+	})
+
+	headers := make([]uint32, 0, len(u.headers))
+	for index := range u.headers {
+		headers = append(headers, index)
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return bits.ReverseBytes32(headers[i]) < bits.ReverseBytes32(headers[j])
+	})
+
+	hashes := make([]util.Uint256, 0, len(u.logs))
+	for h := range u.logs {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i].BytesLE(), hashes[j].BytesLE()) < 0
+	})
+
+	err := u.store.db.Update(func(tx *bbolt.Tx) error {
+		for _, b := range blocks {
+			if err := putBlock(tx, b.block, b.data); err != nil {
+				return err
+			}
+		}
+
+		for _, index := range headers {
+			if err := putHeader(tx, index, u.headers[index].data); err != nil {
+				return err
+			}
+		}
+
+		for _, h := range hashes {
+			if err := putLog(tx, h, u.logs[h].data); err != nil {
+				return err
+			}
+		}
+
+		for _, i := range u.index {
+			if err := indexBlock(tx, i.block, i.logs); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot write buffered blocks to cache: %w", err)
+	}
+
+	u.size = 0
+	u.blocks = make(map[uint32]bulkBlock)
+	u.headers = make(map[uint32]bulkHeader)
+	u.logs = make(map[util.Uint256]bulkLog)
+	u.index = nil
+
+	return nil
+}
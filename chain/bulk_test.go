@@ -0,0 +1,200 @@
+package chain
+
+import (
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"github.com/nspcc-dev/neo-go/pkg/vm/vmstate"
+)
+
+const (
+	// ingestBlocks is the size of the block range written by
+	// BenchmarkIngest.
+	ingestBlocks = 100000
+
+	// ingestWorkers is the amount of parallel writers like fill command
+	// workers.
+	ingestWorkers = 8
+)
+
+type ingestRecord struct {
+	block *block.Block
+	logs  []*result.ApplicationLog
+}
+
+// BenchmarkIngest measures writing of 100k blocks with a transfer
+// transaction each, their application logs and notification index records
+// to the cache with a transaction per record and in bulk ingest mode.
+// Records are generated before the measurement, RPC node is not involved.
+// Every mode takes a while, so run it once:
+//
+//	go test ./chain -run '^$' -bench Ingest -benchtime 1x -timeout 0
+func BenchmarkIngest(b *testing.B) {
+	records := ingestRecords(ingestBlocks)
+
+	modes := []struct {
+		name         string
+		bulk, noSync bool
+	}{
+		{name: "record"},
+		{name: "bulk", bulk: true},
+		{name: "bulk_nosync", bulk: true, noSync: true},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db, err := openCache(filepath.Join(b.TempDir(), "test.db"), false, Options{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				d := &Chain{db: db, store: newBoltStorage(db, false)}
+				b.StartTimer()
+
+				if mode.bulk {
+					if err = d.StartBulk(mode.noSync); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				ingest(b, d, records)
+
+				if err = d.FinishBulk(); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				d.Close()
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// ingest stores records the same way blocks are fetched by fill command.
+func ingest(b *testing.B, d *Chain, records []ingestRecord) {
+	jobs := make(chan ingestRecord)
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < ingestWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				if err := d.addBlock(r.block); err != nil {
+					b.Error(err)
+					return
+				}
+
+				for _, appLog := range r.logs {
+					if err := d.addApplicationLog(appLog.Container, appLog); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+
+				if err := d.index(r.block, r.logs); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for _, r := range records {
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// ingestRecords generates blocks with a GAS transfer transaction each and
+// application logs of blocks and transactions.
+func ingestRecords(n int) []ingestRecord {
+	gas := state.CreateContractHash(util.Uint160{}, 0, "GasToken")
+	res := make([]ingestRecord, 0, n)
+
+	var prev util.Uint256
+	for i := 0; i < n; i++ {
+		from := util.Uint160{1, byte(i), byte(i >> 8), byte(i >> 16)}
+		to := util.Uint160{2, byte(i), byte(i >> 8), byte(i >> 16)}
+
+		tx := transaction.New([]byte{byte(i), byte(i >> 8), byte(i >> 16)}, 1000000)
+		tx.ValidUntilBlock = uint32(i) + 1000
+		tx.Signers = []transaction.Signer{{Account: from, Scopes: transaction.CalledByEntry}}
+		tx.Scripts = []transaction.Witness{{
+			InvocationScript:   make([]byte, 66),
+			VerificationScript: make([]byte, 40),
+		}}
+
+		blk := block.New(false)
+		blk.Index = uint32(i)
+		blk.PrevHash = prev
+		blk.Timestamp = 1600000000000 + uint64(i)*15000
+		blk.Transactions = []*transaction.Transaction{tx}
+		blk.MerkleRoot = blk.ComputeMerkleRoot()
+		blk.Script = transaction.Witness{
+			InvocationScript:   make([]byte, 66),
+			VerificationScript: make([]byte, 40),
+		}
+		prev = blk.Hash()
+
+		transfer := func(from, to stackitem.Item, amount int64) state.NotificationEvent {
+			return state.NotificationEvent{
+				ScriptHash: gas,
+				Name:       "Transfer",
+				Item: stackitem.NewArray([]stackitem.Item{
+					from, to, stackitem.NewBigInteger(big.NewInt(amount)),
+				}),
+			}
+		}
+
+		blockLog := &result.ApplicationLog{
+			Container: blk.Hash(),
+			Executions: []state.Execution{
+				{
+					Trigger: trigger.OnPersist,
+					VMState: vmstate.Halt,
+					Events: []state.NotificationEvent{
+						transfer(stackitem.NewByteArray(from.BytesBE()), stackitem.Null{}, 1000000),
+					},
+				},
+				{
+					Trigger: trigger.PostPersist,
+					VMState: vmstate.Halt,
+					Events: []state.NotificationEvent{
+						transfer(stackitem.Null{}, stackitem.NewByteArray(to.BytesBE()), 50000000),
+					},
+				},
+			},
+		}
+
+		txLog := &result.ApplicationLog{
+			Container:     tx.Hash(),
+			IsTransaction: true,
+			Executions: []state.Execution{{
+				Trigger:     trigger.Application,
+				VMState:     vmstate.Halt,
+				GasConsumed: 1000000,
+				Stack:       []stackitem.Item{stackitem.NewBool(true)},
+				Events: []state.NotificationEvent{
+					transfer(stackitem.NewByteArray(from.BytesBE()), stackitem.NewByteArray(to.BytesBE()), int64(i)),
+				},
+			}},
+		}
+
+		res = append(res, ingestRecord{block: blk, logs: []*result.ApplicationLog{blockLog, txLog}})
+	}
+
+	return res
+}
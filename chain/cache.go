@@ -0,0 +1,499 @@
+package chain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"go.etcd.io/bbolt"
+)
+
+// CacheInfo describes the content of a single network cache.
+type CacheInfo struct {
+	Path     string
+	Magic    uint32
+	Size     int64
+	Blocks   int
+	MinBlock uint32
+	MaxBlock uint32
+	Logs     int
+	Headers  int
+	Version  uint32
+	Identity *Identity
+	// Locked is true if the cache is written by another process, only
+	// size of the cache is known then.
+	Locked bool
+}
+
+// Range is an interval of block indices, both ends are included.
+type Range struct {
+	First uint32
+	Last  uint32
+}
+
+const dbExtension = ".db"
+
+// CachePath returns path to the cache of the network with specified magic.
+func CachePath(dir string, magic uint32) string {
+	return path.Join(dir, strconv.FormatUint(uint64(magic), 10)+dbExtension)
+}
+
+// Caches returns information about every network cache in the dir.
+func Caches(dir string) ([]CacheInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cache dir [%s]: %w", dir, err)
+	}
+
+	res := make([]CacheInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), dbExtension) {
+			continue
+		}
+
+		_, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), dbExtension), 10, 32)
+		if err != nil {
+			continue // not a monza cache
+		}
+
+		info, err := Stat(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, info)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Magic < res[j].Magic })
+
+	return res, nil
+}
+
+// Stat returns information about the cache stored in dbPath.
+func Stat(dbPath string) (info CacheInfo, err error) {
+	info.Path = dbPath
+
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return info, fmt.Errorf("cannot stat database [%s]: %w", dbPath, err)
+	}
+	info.Size = fi.Size()
+
+	magic, err := strconv.ParseUint(strings.TrimSuffix(path.Base(dbPath), dbExtension), 10, 32)
+	if err == nil {
+		info.Magic = uint32(magic)
+	}
+
+	db, err := openReadOnly(dbPath)
+	if errors.Is(err, ErrCacheLocked) {
+		info.Locked = true
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		info.Identity, err = readIdentity(tx)
+		if err != nil {
+			return err
+		}
+
+		info.Version, err = readSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		if bkt := tx.Bucket(logsBucket); bkt != nil {
+			info.Logs = bkt.Stats().KeyN
+		}
+
+		if bkt := tx.Bucket(headersBucket); bkt != nil {
+			info.Headers = bkt.Stats().KeyN
+		}
+
+		bkt := tx.Bucket(blocksBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		return bkt.ForEach(func(k, _ []byte) error {
+			index := binary.LittleEndian.Uint32(k)
+			if info.Blocks == 0 || index < info.MinBlock {
+				info.MinBlock = index
+			}
+			if info.Blocks == 0 || index > info.MaxBlock {
+				info.MaxBlock = index
+			}
+			info.Blocks++
+			return nil
+		})
+	})
+	if err != nil {
+		return info, fmt.Errorf("cannot read database [%s]: %w", dbPath, err)
+	}
+
+	return info, nil
+}
+
+// Ranges returns contiguous ranges of blocks stored in the cache in dbPath.
+func Ranges(dbPath string) ([]Range, error) {
+	return keyRanges(dbPath, blocksBucket)
+}
+
+// HeaderRanges returns contiguous ranges of headers stored in the cache in
+// dbPath without blocks.
+func HeaderRanges(dbPath string) ([]Range, error) {
+	return keyRanges(dbPath, headersBucket)
+}
+
+func keyRanges(dbPath string, bucket []byte) ([]Range, error) {
+	db, err := openReadOnly(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var indices []uint32
+	err = db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(bucket)
+		if bkt == nil {
+			return nil
+		}
+
+		return bkt.ForEach(func(k, _ []byte) error {
+			indices = append(indices, binary.LittleEndian.Uint32(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read database [%s]: %w", dbPath, err)
+	}
+
+	return toRanges(indices), nil
+}
+
+// Incomplete returns ranges of blocks stored in the cache in dbPath which
+// miss application log of the block itself or any of its transactions.
+func Incomplete(dbPath string) ([]Range, error) {
+	db, err := openReadOnly(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var indices []uint32
+	err = db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(blocksBucket)
+		if bkt == nil {
+			return nil
+		}
+		logsBkt := tx.Bucket(logsBucket)
+
+		return bkt.ForEach(func(k, v []byte) error {
+			index := binary.LittleEndian.Uint32(k)
+
+			b, err := decodeAnyBlock(v)
+			if err != nil {
+				return fmt.Errorf("cannot decode block %d: %w", index, err)
+			}
+
+			if !logsCached(logsBkt, b) {
+				indices = append(indices, index)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read database [%s]: %w", dbPath, err)
+	}
+
+	return toRanges(indices), nil
+}
+
+// Missing returns indices of blocks in [from, to) interval which are not
+// cached or miss application log of the block itself or any of its
+// transactions.
+func (d *Chain) Missing(from, to uint32) ([]uint32, error) {
+	var res []uint32
+
+	if d.db == nil {
+		return d.missing(from, to)
+	}
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		blocksBkt := tx.Bucket(blocksBucket)
+		logsBkt := tx.Bucket(logsBucket)
+
+		key := make([]byte, 4)
+		for i := from; i < to; i++ {
+			if blocksBkt == nil {
+				res = append(res, i)
+				continue
+			}
+
+			binary.LittleEndian.PutUint32(key, i)
+			data := blocksBkt.Get(key)
+			if len(data) == 0 {
+				res = append(res, i)
+				continue
+			}
+
+			b, err := decodeBlock(data, d.stateRoot)
+			if err != nil {
+				return fmt.Errorf("cannot decode block %d: %w", i, err)
+			}
+
+			if !logsCached(logsBkt, b) {
+				res = append(res, i)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cache: %w", err)
+	}
+
+	return res, nil
+}
+
+// missing returns indices of blocks missing in the storage, see Missing.
+func (d *Chain) missing(from, to uint32) ([]uint32, error) {
+	var res []uint32
+
+	for i := from; i < to; i++ {
+		b, err := d.block(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if b == nil {
+			res = append(res, i)
+			continue
+		}
+
+		for _, h := range containers(b) {
+			appLog, err := d.applicationLog(h)
+			if err != nil {
+				return nil, err
+			}
+			if appLog == nil {
+				res = append(res, i)
+				break
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// DeleteCache removes the cache stored in dbPath. It returns ErrCacheLocked
+// if the cache is opened by another process.
+func DeleteCache(dbPath string) error {
+	// hold exclusive lock, so the cache is not removed under the process
+	// which works with it
+	db, err := openDB(dbPath, false, maintenanceLockTimeout, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err = os.Remove(dbPath); err != nil {
+		return fmt.Errorf("cannot remove database [%s]: %w", dbPath, err)
+	}
+
+	return nil
+}
+
+// DeleteBlocks removes blocks in [from, to) interval from the cache stored
+// in dbPath together with application logs of these blocks and their
+// transactions and cached headers. Returns amount of removed blocks and
+// application logs.
+func DeleteBlocks(dbPath string, from, to uint32) (blocks, logs int, err error) {
+	db, err := openDB(dbPath, false, maintenanceLockTimeout, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if err := deleteHeaders(tx, from, to); err != nil {
+			return err
+		}
+
+		blocksBkt := tx.Bucket(blocksBucket)
+		if blocksBkt == nil {
+			return nil
+		}
+		logsBkt := tx.Bucket(logsBucket)
+
+		// bbolt does not support removal of the keys while iterating
+		// over them, so collect the keys first
+		var keys [][]byte
+		err := blocksBkt.ForEach(func(k, v []byte) error {
+			index := binary.LittleEndian.Uint32(k)
+			if index < from || index >= to {
+				return nil
+			}
+
+			keys = append(keys, append([]byte{}, k...))
+
+			b, err := decodeAnyBlock(v)
+			if err != nil {
+				return fmt.Errorf("cannot decode block %d: %w", index, err)
+			}
+
+			if err = deleteHashes(tx, b); err != nil {
+				return err
+			}
+
+			if logsBkt == nil {
+				return nil
+			}
+
+			if err = unindexBlock(tx, b); err != nil {
+				return err
+			}
+
+			n, err := deleteLogs(logsBkt, b)
+			logs += n
+
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := blocksBkt.Delete(k); err != nil {
+				return err
+			}
+		}
+		blocks = len(keys)
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot delete blocks from database [%s]: %w", dbPath, err)
+	}
+
+	return blocks, logs, nil
+}
+
+// Compact rewrites the cache stored in dbPath to reclaim free pages left
+// after deletions. Returns size of the database before and after compaction.
+func Compact(dbPath string) (before, after int64, err error) {
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot stat database [%s]: %w", dbPath, err)
+	}
+	before = fi.Size()
+
+	// open source database in read-write mode to hold exclusive lock,
+	// so nobody updates it while it is being compacted
+	src, err := openDB(dbPath, false, maintenanceLockTimeout, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.Close()
+
+	tmpPath := dbPath + ".compact"
+	dst, err := bbolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("database [%s] init: %w", tmpPath, err)
+	}
+
+	err = bbolt.Compact(dst, src, compactTxSize)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("cannot compact database [%s]: %w", dbPath, err)
+	}
+
+	err = os.Rename(tmpPath, dbPath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("cannot replace database [%s]: %w", dbPath, err)
+	}
+
+	fi, err = os.Stat(dbPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot stat database [%s]: %w", dbPath, err)
+	}
+
+	return before, fi.Size(), nil
+}
+
+func openReadOnly(dbPath string) (*bbolt.DB, error) {
+	return openDB(dbPath, true, maintenanceLockTimeout, nil)
+}
+
+// logsCached checks if application logs of the block and all of its
+// transactions are stored in the bucket.
+func logsCached(bkt *bbolt.Bucket, b *block.Block) bool {
+	if bkt == nil || bkt.Get(b.Hash().BytesLE()) == nil {
+		return false
+	}
+
+	for _, tx := range b.Transactions {
+		if bkt.Get(tx.Hash().BytesLE()) == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deleteLogs removes application logs of the block and its transactions
+// from the bucket. Returns amount of removed logs.
+func deleteLogs(bkt *bbolt.Bucket, b *block.Block) (int, error) {
+	// block hash is a key of the application log for
+	// OnPersist and PostPersist triggers
+	hashes := [][]byte{b.Hash().BytesLE()}
+	for _, tx := range b.Transactions {
+		hashes = append(hashes, tx.Hash().BytesLE())
+	}
+
+	var res int
+	for _, h := range hashes {
+		if bkt.Get(h) == nil {
+			continue
+		}
+		if err := bkt.Delete(h); err != nil {
+			return res, err
+		}
+		res++
+	}
+
+	return res, nil
+}
+
+func toRanges(indices []uint32) []Range {
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	var res []Range
+	for _, index := range indices {
+		if ln := len(res); ln != 0 && res[ln-1].Last+1 == index {
+			res[ln-1].Last = index
+			continue
+		}
+		res = append(res, Range{First: index, Last: index})
+	}
+
+	return res
+}
+
+// compactTxSize is a size of data copied in a single transaction during
+// compaction.
+const compactTxSize = 64 << 20
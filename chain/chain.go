@@ -3,23 +3,62 @@ package chain
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"path"
-	"strconv"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/nspcc-dev/neo-go/pkg/core/block"
 	"github.com/nspcc-dev/neo-go/pkg/core/state"
-	"github.com/nspcc-dev/neo-go/pkg/io"
 	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
 	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/manifest"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
 	"github.com/nspcc-dev/neo-go/pkg/util"
 	"go.etcd.io/bbolt"
 )
 
 type Chain struct {
-	db        *bbolt.DB
+	store Storage
+	// db is the cache file of bbolt storage, notification index and lookup
+	// of blocks by hashes are available only with it
+	db *bbolt.DB
+	// bulk buffers fetched data in bulk ingest mode
+	bulk *bulk
+
 	stateRoot bool
-	Client    *rpcclient.Client
+	magic     uint32
+	genesis   util.Uint256
+	verifying bool
+	endpoints []*endpoint
+
+	// workers is the limit of parallel requests to every endpoint
+	workers int
+	schedMu sync.Mutex
+	// released is closed when any endpoint slot is released
+	released chan struct{}
+
+	retry   retryPolicy
+	limiter *limiter
+
+	countMu sync.Mutex
+	// offlineCount is the block count of offline chain
+	offlineCount uint32
+
+	// verifyMu serializes verification of headers preceding fetched blocks
+	verifyMu sync.Mutex
+	anchorMu sync.Mutex
+	// anchors keeps latest verified blocks if the cache is not writable
+	anchors map[uint32]*anchor
+
+	contractsMu sync.Mutex
+	// contracts keeps ABI of contracts requested by EventParameters, ABI
+	// of unknown contracts is nil
+	contracts map[util.Uint160]*manifest.ABI
+	// nns is the script hash of NNS contract set by SetNNSContract or
+	// requested by ResolveNNS
+	nns *util.Uint160
 }
 
 var (
@@ -27,114 +66,456 @@ var (
 	logsBucket   = []byte("logs")
 )
 
-func Open(ctx context.Context, dir, endpoint string) (*Chain, error) {
-	cli, err := rpcclient.New(ctx, endpoint, rpcclient.Options{})
+// ErrNotCached is returned in offline mode when requested data is missing
+// in the cache.
+var ErrNotCached = errors.New("not cached in offline mode")
+
+// Options contains optional parameters of the chain.
+type Options struct {
+	// MigrationProgress is called during upgrade of the cache schema.
+	MigrationProgress MigrationProgress
+
+	// Verify enables verification of fetched blocks, see Chain.Verify.
+	Verify bool
+
+	// EndpointWorkers limits the amount of parallel requests to every RPC
+	// endpoint, DefaultEndpointWorkers is used if not set.
+	EndpointWorkers int
+
+	// RequestTimeout limits the duration of every RPC request, default
+	// timeout of the RPC client is used if not set.
+	RequestTimeout time.Duration
+
+	// Retries is the amount of repeated attempts of the failed request.
+	Retries int
+
+	// RetryDelay is the delay before the first retry, it doubles with every
+	// next retry up to MaxRetryDelay. DefaultRetryDelay and
+	// DefaultMaxRetryDelay are used if not set.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// RequestsPerSecond limits the rate of RPC requests to all endpoints,
+	// rate is not limited if not set.
+	RequestsPerSecond float64
+
+	// LockTimeout limits waiting for the cache file locked by another
+	// process, chain waits until the lock is released if not set.
+	LockTimeout time.Duration
+
+	// NoCacheOnLock makes Open work without the cache file if another
+	// process does not release it in LockTimeout. Fetched blocks and
+	// application logs are not stored then. Otherwise ErrCacheLocked is
+	// returned.
+	NoCacheOnLock bool
+
+	// Bulk starts bulk ingest mode of the cache opened by Open, see
+	// StartBulk. It is finished by FinishBulk or Close.
+	Bulk bool
+
+	// NoSync defers syncing of the cache file in bulk ingest mode, see
+	// StartBulk.
+	NoSync bool
+
+	// CacheLocked is called before waiting for the cache file locked by
+	// another process.
+	CacheLocked func(dbPath string)
+}
+
+// Open connects to the RPC endpoints and opens the cache of the network
+// they serve. Every endpoint must serve the same chain. Failed requests are
+// repeated with other endpoints. The cache is locked exclusively until the
+// chain is closed.
+func Open(ctx context.Context, dir string, endpoints []string, opts Options) (*Chain, error) {
+	d, id, err := connect(ctx, endpoints, opts)
 	if err != nil {
-		return nil, fmt.Errorf("rpc connection: %w", err)
+		return nil, err
+	}
+
+	dbPath := CachePath(dir, d.magic)
+
+	db, err := openCache(dbPath, false, opts)
+	if errors.Is(err, ErrCacheLocked) && opts.NoCacheOnLock {
+		// memory storage grows with every fetched block
+		d.store = nopStorage{}
+		return d, nil
 	}
 
-	err = cli.Init()
 	if err != nil {
-		return nil, fmt.Errorf("rpc client initialization: %w", err)
+		d.Close()
+		return nil, err
 	}
 
-	v, err := cli.GetVersion()
+	err = checkIdentity(db, id)
 	if err != nil {
-		return nil, fmt.Errorf("rpc get version: %w", err)
+		_ = db.Close()
+		d.Close()
+		if errors.Is(err, ErrChainMismatch) {
+			return nil, fmt.Errorf("database [%s]: %w (move the file aside or remove it to start a new cache)", dbPath, err)
+		}
+		return nil, fmt.Errorf("database [%s] chain identity check: %w", dbPath, err)
 	}
 
-	dbPath := path.Join(dir, strconv.Itoa(int(v.Protocol.Network))+".db")
+	d.db = db
+	d.store = newBoltStorage(db, d.stateRoot)
+
+	if opts.Bulk {
+		if err = d.StartBulk(opts.NoSync); err != nil {
+			d.Close()
+			return nil, err
+		}
+	}
 
-	db, err := bbolt.Open(dbPath, 0600, nil)
+	return d, nil
+}
+
+// OpenStorage connects to the RPC endpoints and uses the storage to cache
+// blocks and application logs, see Open. Notification index and lookup of
+// blocks by hashes in the storage are not available, such requests are sent
+// to the RPC nodes.
+func OpenStorage(ctx context.Context, s Storage, endpoints []string, opts Options) (*Chain, error) {
+	d, _, err := connect(ctx, endpoints, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	genesis, err := s.Block(0)
 	if err != nil {
+		d.Close()
+		return nil, fmt.Errorf("cannot read genesis block from storage: %w", err)
+	}
+
+	if genesis != nil && !genesis.Hash().Equals(d.genesis) {
+		d.Close()
+		return nil, fmt.Errorf("%w: stored genesis block does not match genesis %s of remote node",
+			ErrChainMismatch, d.genesis.StringLE())
+	}
+
+	d.store = s
+
+	return d, nil
+}
+
+// connect validates options and dials the RPC endpoints. Returned chain has
+// no storage.
+func connect(ctx context.Context, endpoints []string, opts Options) (*Chain, Identity, error) {
+	if len(endpoints) == 0 {
+		return nil, Identity{}, errors.New("no rpc endpoints specified")
+	}
+
+	if opts.EndpointWorkers < 0 {
+		return nil, Identity{}, fmt.Errorf("invalid amount of endpoint workers %d", opts.EndpointWorkers)
+	} else if opts.EndpointWorkers == 0 {
+		opts.EndpointWorkers = DefaultEndpointWorkers
+	}
+
+	if opts.Retries < 0 {
+		return nil, Identity{}, fmt.Errorf("invalid amount of retries %d", opts.Retries)
+	}
+
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = DefaultMaxRetryDelay
+	}
+
+	retry := retryPolicy{
+		attempts: opts.Retries,
+		delay:    opts.RetryDelay,
+		maxDelay: opts.MaxRetryDelay,
+	}
+
+	nodes, v, genesis, err := dialEndpoints(ctx, endpoints, rpcclient.Options{
+		RequestTimeout: opts.RequestTimeout,
+	}, retry)
+	if err != nil {
+		return nil, Identity{}, err
+	}
+
+	id := Identity{
+		Genesis:              genesis,
+		Magic:                uint32(v.Protocol.Network),
+		StateRootInHeader:    v.Protocol.StateRootInHeader,
+		MillisecondsPerBlock: uint32(v.Protocol.MillisecondsPerBlock),
+	}
+
+	return &Chain{
+		stateRoot: id.StateRootInHeader,
+		magic:     id.Magic,
+		genesis:   id.Genesis,
+		verifying: opts.Verify,
+		endpoints: nodes,
+		workers:   opts.EndpointWorkers,
+		released:  make(chan struct{}),
+		retry:     retry,
+		limiter:   newLimiter(opts.RequestsPerSecond),
+	}, id, nil
+}
+
+// OpenOffline opens the cache stored in dbPath without connection to the
+// RPC node. Such chain returns ErrNotCached for every block or application
+// log missing in the cache. The cache is opened read-only, so other
+// processes may read it at the same time.
+func OpenOffline(dbPath string, opts Options) (*Chain, error) {
+	if opts.EndpointWorkers <= 0 {
+		opts.EndpointWorkers = DefaultEndpointWorkers
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
 		return nil, fmt.Errorf("database [%s] init: %w", dbPath, err)
 	}
 
-	return &Chain{db, v.Protocol.StateRootInHeader, cli}, nil
+	db, err := openCache(dbPath, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var id *Identity
+	err = db.View(func(tx *bbolt.Tx) error {
+		id, err = readIdentity(tx)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("database [%s] chain identity read: %w", dbPath, err)
+	}
+
+	if id == nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("database [%s] has no chain identity, open it with RPC node once", dbPath)
+	}
+
+	return &Chain{
+		store:     newBoltStorage(db, id.StateRootInHeader),
+		db:        db,
+		stateRoot: id.StateRootInHeader,
+		magic:     id.Magic,
+		genesis:   id.Genesis,
+		workers:   opts.EndpointWorkers,
+	}, nil
 }
 
-func (d *Chain) Block(i uint32) (res *block.Block, err error) {
-	cached, err := d.block(i)
+// openCache opens the cache file and upgrades its schema. Read-only cache
+// is upgraded with exclusive lock before it is opened.
+func openCache(dbPath string, readOnly bool, opts Options) (*bbolt.DB, error) {
+	db, err := openDB(dbPath, readOnly, opts.LockTimeout, opts.CacheLocked)
 	if err != nil {
 		return nil, err
 	}
 
-	if cached != nil {
-		return cached, nil
+	if readOnly {
+		version, err := schemaVersion(db)
+		if err == nil && version == SchemaVersion {
+			return db, nil
+		}
+
+		_ = db.Close()
+		if err != nil {
+			return nil, fmt.Errorf("database [%s] schema read: %w", dbPath, err)
+		}
+
+		db, err = openCache(dbPath, false, opts)
+		if err != nil {
+			return nil, err
+		}
+		_ = db.Close()
+
+		return openDB(dbPath, true, opts.LockTimeout, opts.CacheLocked)
 	}
 
-	metaBlock, err := d.Client.GetBlockByIndexVerbose(i)
+	err = migrate(db, opts.MigrationProgress)
 	if err != nil {
-		return nil, fmt.Errorf("block %d fetch: %w", i, err)
+		_ = db.Close()
+		return nil, fmt.Errorf("database [%s] schema upgrade: %w", dbPath, err)
 	}
 
-	return &metaBlock.Block, d.addBlock(&metaBlock.Block)
+	return db, nil
 }
 
-func (d *Chain) BlockByHash(h util.Uint256) (res *block.Block, err error) {
-	rev := h.Reverse()
-	metaBlock, err := d.Client.GetBlockByHashVerbose(rev)
-	if err != nil {
-		return nil, fmt.Errorf("block %s fetch: %w", h.StringLE(), err)
+// CacheFile returns path to the cache file or empty string if chain works
+// without it.
+func (d *Chain) CacheFile() string {
+	if d.db == nil {
+		return ""
 	}
 
-	return &metaBlock.Block, d.addBlock(&metaBlock.Block)
+	return d.db.Path()
+}
+
+// Magic returns the magic number of the network.
+func (d *Chain) Magic() uint32 {
+	return d.magic
 }
 
-func (d *Chain) block(i uint32) (res *block.Block, err error) {
-	err = d.db.View(func(tx *bbolt.Tx) error {
-		key := make([]byte, 4)
-		binary.LittleEndian.PutUint32(key, i)
+// Offline returns true if chain works without connection to the RPC node.
+func (d *Chain) Offline() bool {
+	return len(d.endpoints) == 0
+}
+
+// BlockCount returns the amount of blocks in the chain. In offline mode it
+// is defined by the highest cached block, it is looked up once, because
+// offline chain does not fetch blocks.
+func (d *Chain) BlockCount(ctx context.Context) (uint32, error) {
+	if !d.Offline() {
+		var count uint32
+		err := d.call(ctx, func(cli *rpcclient.Client) (err error) {
+			count, err = cli.GetBlockCount()
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		return count, nil
+	}
+
+	d.countMu.Lock()
+	defer d.countMu.Unlock()
+
+	if d.offlineCount != 0 {
+		return d.offlineCount, nil
+	}
 
+	var (
+		count uint32
+		found bool
+	)
+
+	// keys are little endian, so the highest block is not the last key
+	err := d.db.View(func(tx *bbolt.Tx) error {
 		bkt := tx.Bucket(blocksBucket)
 		if bkt == nil {
 			return nil
 		}
 
-		data := bkt.Get(key)
-		if len(data) == 0 {
+		return bkt.ForEach(func(k, _ []byte) error {
+			if index := binary.LittleEndian.Uint32(k); !found || index >= count {
+				count, found = index+1, true
+			}
 			return nil
-		}
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot read cache: %w", err)
+	}
+
+	if !found {
+		return 0, fmt.Errorf("%w: cache is empty", ErrNotCached)
+	}
+
+	d.offlineCount = count
+
+	return count, nil
+}
+
+func (d *Chain) Block(ctx context.Context, i uint32) (*block.Block, error) {
+	cached, err := d.block(i)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		return cached, nil
+	}
 
-		res = block.New(d.stateRoot)
-		r := io.NewBinReaderFromBuf(data)
-		res.DecodeBinary(r)
+	if d.Offline() {
+		return nil, fmt.Errorf("%w: block %d", ErrNotCached, i)
+	}
 
-		return r.Err
+	var metaBlock *result.Block
+	err = d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		metaBlock, err = cli.GetBlockByIndexVerbose(i)
+		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("cannot read block %d from cache: %w", i, err)
+		return nil, fmt.Errorf("block %d fetch: %w", i, err)
 	}
 
-	return res, nil
+	if d.verifying {
+		if metaBlock.Index != i {
+			return nil, fmt.Errorf("%w %d: node returned block %d", ErrInvalidBlock, i, metaBlock.Index)
+		}
+
+		err = d.verify(ctx, &metaBlock.Block, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &metaBlock.Block, d.addBlock(&metaBlock.Block)
 }
 
-func (d *Chain) addBlock(block *block.Block) error {
-	err := d.db.Batch(func(tx *bbolt.Tx) error {
-		key := make([]byte, 4)
-		binary.LittleEndian.PutUint32(key, block.Index)
-
-		w := io.NewBufBinWriter()
-		block.EncodeBinary(w.BinWriter)
-		if w.Err != nil {
-			return w.Err
+// BlockByHash returns the block with the hash in the order Block.Hash returns
+// it, so hashes decoded from their LE strings are passed as is. Blocks in the
+// cache are looked up first.
+func (d *Chain) BlockByHash(ctx context.Context, h util.Uint256) (*block.Block, error) {
+	index, ok, err := d.lookup(hashesBucket, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return d.Block(ctx, index)
+	}
+
+	if d.Offline() {
+		return nil, fmt.Errorf("%w: block %s", ErrNotCached, h.StringLE())
+	}
+
+	var metaBlock *result.Block
+	err = d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		metaBlock, err = cli.GetBlockByHashVerbose(h)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("block %s fetch: %w", h.StringLE(), err)
+	}
+
+	if d.verifying {
+		if !metaBlock.Hash().Equals(h) {
+			return nil, fmt.Errorf("%w %d: hash mismatch", ErrInvalidBlock, metaBlock.Index)
 		}
 
-		bkt, err := tx.CreateBucketIfNotExists(blocksBucket)
+		err = d.verify(ctx, &metaBlock.Block, nil)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	return &metaBlock.Block, d.addBlock(&metaBlock.Block)
+}
+
+func (d *Chain) block(i uint32) (*block.Block, error) {
+	if d.bulk != nil {
+		if res := d.bulk.block(i); res != nil {
+			return res, nil
 		}
+	}
 
-		return bkt.Put(key, w.Bytes())
-	})
+	res, err := d.store.Block(i)
 	if err != nil {
+		return nil, fmt.Errorf("cannot read block %d from cache: %w", i, err)
+	}
+
+	return res, nil
+}
+
+func (d *Chain) addBlock(block *block.Block) error {
+	var err error
+	if d.bulk != nil {
+		err = d.bulk.putBlock(block)
+	} else {
+		err = d.store.PutBlock(block)
+	}
+	if err != nil && !errors.Is(err, ErrReadOnly) {
 		return fmt.Errorf("cannot add block %d to cache: %w", block.Index, err)
 	}
 
 	return nil
 }
 
-func (d *Chain) ApplicationLog(txHash util.Uint256) (*result.ApplicationLog, error) {
+func (d *Chain) ApplicationLog(ctx context.Context, txHash util.Uint256) (*result.ApplicationLog, error) {
 	cached, err := d.applicationLog(txHash)
 	if err != nil {
 		return nil, err
@@ -144,7 +525,15 @@ func (d *Chain) ApplicationLog(txHash util.Uint256) (*result.ApplicationLog, err
 		return cached, nil
 	}
 
-	appLog, err := d.Client.GetApplicationLog(txHash, nil)
+	if d.Offline() {
+		return nil, fmt.Errorf("%w: app log of tx %s", ErrNotCached, txHash.StringLE())
+	}
+
+	var appLog *result.ApplicationLog
+	err = d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		appLog, err = cli.GetApplicationLog(txHash, nil)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("app log of tx %s fetch: %w", txHash.StringLE(), err)
 	}
@@ -152,21 +541,14 @@ func (d *Chain) ApplicationLog(txHash util.Uint256) (*result.ApplicationLog, err
 	return appLog, d.addApplicationLog(txHash, appLog)
 }
 
-func (d *Chain) applicationLog(txHash util.Uint256) (res *result.ApplicationLog, err error) {
-	err = d.db.View(func(tx *bbolt.Tx) error {
-		bkt := tx.Bucket(logsBucket)
-		if bkt == nil {
-			return nil
-		}
-
-		data := bkt.Get(txHash.BytesLE())
-		if len(data) == 0 {
-			return nil
+func (d *Chain) applicationLog(txHash util.Uint256) (*result.ApplicationLog, error) {
+	if d.bulk != nil {
+		if res := d.bulk.applicationLog(txHash); res != nil {
+			return res, nil
 		}
+	}
 
-		res = new(result.ApplicationLog)
-		return res.UnmarshalJSON(bkt.Get(txHash.BytesLE()))
-	})
+	res, err := d.store.ApplicationLog(txHash)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read tx %s from cache: %w", txHash.StringLE(), err)
 	}
@@ -175,28 +557,21 @@ func (d *Chain) applicationLog(txHash util.Uint256) (res *result.ApplicationLog,
 }
 
 func (d *Chain) addApplicationLog(txHash util.Uint256, appLog *result.ApplicationLog) error {
-	err := d.db.Batch(func(tx *bbolt.Tx) error {
-		val, err := appLog.MarshalJSON()
-		if err != nil {
-			return err
-		}
-
-		bkt, err := tx.CreateBucketIfNotExists(logsBucket)
-		if err != nil {
-			return err
-		}
-
-		return bkt.Put(txHash.BytesLE(), val)
-	})
-	if err != nil {
+	var err error
+	if d.bulk != nil {
+		err = d.bulk.putApplicationLog(txHash, appLog)
+	} else {
+		err = d.store.PutApplicationLog(txHash, appLog)
+	}
+	if err != nil && !errors.Is(err, ErrReadOnly) {
 		return fmt.Errorf("cannot add tx %s to cache: %w", txHash.StringLE(), err)
 	}
 
 	return nil
 }
 
-func (d *Chain) Notifications(txHash util.Uint256) ([]state.NotificationEvent, error) {
-	appLog, err := d.ApplicationLog(txHash)
+func (d *Chain) Notifications(ctx context.Context, txHash util.Uint256) ([]state.NotificationEvent, error) {
+	appLog, err := d.ApplicationLog(ctx, txHash)
 	if err != nil {
 		return nil, err
 	}
@@ -209,31 +584,60 @@ func (d *Chain) Notifications(txHash util.Uint256) ([]state.NotificationEvent, e
 	return res, nil
 }
 
-func (d *Chain) AllNotifications(b *block.Block) ([]state.NotificationEvent, error) {
-	res := make([]state.NotificationEvent, 0, 0)
+// Event is a notification event with the context of its execution.
+type Event struct {
+	state.NotificationEvent
+	// Container is a hash of the transaction or the block which produced
+	// the event.
+	Container util.Uint256
+	Trigger   trigger.Type
+}
 
-	appLog, err := d.ApplicationLog(b.Hash())
+// AllNotifications returns notifications of the block and its transactions.
+// Notifications of the block are added to the notification index.
+func (d *Chain) AllNotifications(ctx context.Context, b *block.Block) ([]state.NotificationEvent, error) {
+	events, err := d.BlockEvents(ctx, b)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, execution := range appLog.Executions {
-		res = append(res, execution.Events...)
+	res := make([]state.NotificationEvent, 0, len(events))
+	for _, ev := range events {
+		res = append(res, ev.NotificationEvent)
 	}
 
-	for _, tx := range b.Transactions {
-		appLog, err = d.ApplicationLog(tx.Hash())
+	return res, nil
+}
+
+// BlockEvents returns notification events of the block and its transactions
+// in the order of AllNotifications. Notifications of the block are added to
+// the notification index.
+func (d *Chain) BlockEvents(ctx context.Context, b *block.Block) ([]Event, error) {
+	var (
+		res  = make([]Event, 0, 0)
+		logs = make([]*result.ApplicationLog, 0, len(b.Transactions)+1)
+	)
+
+	for _, h := range containers(b) {
+		appLog, err := d.ApplicationLog(ctx, h)
 		if err != nil {
 			return nil, err
 		}
-		for _, execution := range appLog.Executions {
-			res = append(res, execution.Events...)
-		}
+		logs = append(logs, appLog)
+		res = append(res, executionEvents(h, appLog)...)
 	}
 
-	return res, nil
+	return res, d.index(b, logs)
 }
 
-func (d Chain) Close() {
-	_ = d.db.Close()
+func (d *Chain) Close() {
+	for _, e := range d.endpoints {
+		if e.client != nil {
+			e.client.Close()
+		}
+	}
+	if d.store != nil {
+		_ = d.FinishBulk()
+		_ = d.store.Close()
+	}
 }
@@ -0,0 +1,254 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/manifest"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"github.com/nspcc-dev/neo-go/pkg/vm/vmstate"
+)
+
+const (
+	// nnsContractID is the ID of NNS contract if it is the first deployed
+	// contract of the chain like in NeoFS chains.
+	nnsContractID = 1
+
+	// nnsManifestName is the name of NNS contract in its manifest.
+	nnsManifestName = "NameService"
+
+	// nnsTXTRecord is the type of NNS records with script hashes of
+	// contracts.
+	nnsTXTRecord = 16
+)
+
+// ErrUnknownNNS is returned by ResolveNNS if NNS contract is not set and
+// the first deployed contract of the chain is not NNS.
+var ErrUnknownNNS = errors.New("NNS contract is unknown")
+
+// nativeNames are names of native contracts used in offline mode.
+var nativeNames = []string{
+	nativenames.Management,
+	nativenames.Ledger,
+	nativenames.Neo,
+	nativenames.Gas,
+	nativenames.Policy,
+	nativenames.Oracle,
+	nativenames.Designation,
+	nativenames.Notary,
+	nativenames.CryptoLib,
+	nativenames.StdLib,
+}
+
+// NativeContracts returns script hashes of native contracts by their names,
+// e.g. GasToken. In offline mode hashes of known native contracts are
+// calculated.
+func (d *Chain) NativeContracts(ctx context.Context) (map[string]util.Uint160, error) {
+	if d.Offline() {
+		res := make(map[string]util.Uint160, len(nativeNames))
+		for _, name := range nativeNames {
+			// native contracts are deployed by zero sender with zero checksum
+			res[name] = state.CreateContractHash(util.Uint160{}, 0, name)
+		}
+		return res, nil
+	}
+
+	var natives []state.NativeContract
+	err := d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		natives, err = cli.GetNativeContracts()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch native contracts: %w", err)
+	}
+
+	res := make(map[string]util.Uint160, len(natives))
+	for _, cs := range natives {
+		res[cs.Manifest.Name] = cs.Hash
+	}
+
+	return res, nil
+}
+
+// ResolveNNS returns script hash from TXT record of the domain name in NNS
+// contract, e.g. netmap.neofs. Record contains an address or LE script hash.
+// NNS contract is the one set by SetNNSContract or the contract with ID 1.
+func (d *Chain) ResolveNNS(ctx context.Context, name string) (util.Uint160, error) {
+	if d.Offline() {
+		return util.Uint160{}, fmt.Errorf("cannot resolve NNS name %s: %w", name, ErrNotCached)
+	}
+
+	nns, err := d.nnsContract(ctx)
+	if err != nil {
+		return util.Uint160{}, err
+	}
+
+	var res *result.Invoke
+	err = d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		res, err = cli.InvokeFunction(nns, "resolve", []smartcontract.Parameter{
+			{Type: smartcontract.StringType, Value: name},
+			{Type: smartcontract.IntegerType, Value: big.NewInt(nnsTXTRecord)},
+		}, nil)
+		return err
+	})
+	if err != nil {
+		return util.Uint160{}, fmt.Errorf("cannot resolve NNS name %s: %w", name, err)
+	}
+
+	if res.State != vmstate.Halt.String() {
+		return util.Uint160{}, fmt.Errorf("cannot resolve NNS name %s: %s", name, res.FaultException)
+	}
+
+	record, err := nnsRecord(res.Stack)
+	if err != nil {
+		return util.Uint160{}, fmt.Errorf("cannot resolve NNS name %s: %w", name, err)
+	}
+
+	if h, err := address.StringToUint160(record); err == nil {
+		return h, nil
+	}
+
+	h, err := util.Uint160DecodeStringLE(record)
+	if err != nil {
+		return util.Uint160{}, fmt.Errorf("NNS name %s has invalid record %s", name, record)
+	}
+
+	return h, nil
+}
+
+// nnsRecord returns the first record of resolve method result. Versions of
+// NNS contract return a string or an array of strings.
+func nnsRecord(stack []stackitem.Item) (string, error) {
+	if len(stack) == 0 {
+		return "", errors.New("empty result stack")
+	}
+
+	item := stack[0]
+	if arr, ok := item.Value().([]stackitem.Item); ok {
+		if len(arr) == 0 {
+			return "", errors.New("no records")
+		}
+		item = arr[0]
+	}
+
+	if item.Type() == stackitem.AnyT {
+		return "", errors.New("no records")
+	}
+
+	data, err := item.TryBytes()
+	if err != nil {
+		return "", fmt.Errorf("invalid record: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// SetNNSContract sets the script hash of NNS contract used by ResolveNNS
+// instead of the contract with ID 1.
+func (d *Chain) SetNNSContract(h util.Uint160) {
+	d.contractsMu.Lock()
+	d.nns = &h
+	d.contractsMu.Unlock()
+}
+
+func (d *Chain) nnsContract(ctx context.Context) (util.Uint160, error) {
+	d.contractsMu.Lock()
+	nns := d.nns
+	d.contractsMu.Unlock()
+
+	if nns != nil {
+		return *nns, nil
+	}
+
+	var cs *state.Contract
+	err := d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		cs, err = cli.GetContractStateByID(nnsContractID)
+		return err
+	})
+	switch {
+	case err == nil:
+	case permanent(err):
+		return util.Uint160{}, fmt.Errorf("%w: no contract with ID %d", ErrUnknownNNS, nnsContractID)
+	default:
+		return util.Uint160{}, fmt.Errorf("cannot fetch NNS contract: %w", err)
+	}
+
+	if cs.Manifest.Name != nnsManifestName {
+		return util.Uint160{}, fmt.Errorf("%w: contract %s with ID %d is %s",
+			ErrUnknownNNS, cs.Hash.StringLE(), nnsContractID, cs.Manifest.Name)
+	}
+
+	d.contractsMu.Lock()
+	d.nns = &cs.Hash
+	d.contractsMu.Unlock()
+
+	return cs.Hash, nil
+}
+
+// EventParameters returns names of the event parameters declared in the
+// manifest of the contract. Manifest of every contract is requested once.
+// It returns nil if the contract or the event is unknown, e.g. contract is
+// destroyed, and ErrNotCached in offline mode.
+func (d *Chain) EventParameters(ctx context.Context, contract util.Uint160, name string) ([]string, error) {
+	abi, err := d.contractABI(ctx, contract)
+	if err != nil || abi == nil {
+		return nil, err
+	}
+
+	for _, ev := range abi.Events {
+		if ev.Name != name {
+			continue
+		}
+
+		res := make([]string, 0, len(ev.Parameters))
+		for _, p := range ev.Parameters {
+			res = append(res, p.Name)
+		}
+
+		return res, nil
+	}
+
+	return nil, nil
+}
+
+func (d *Chain) contractABI(ctx context.Context, contract util.Uint160) (*manifest.ABI, error) {
+	d.contractsMu.Lock()
+	abi, ok := d.contracts[contract]
+	d.contractsMu.Unlock()
+
+	if ok {
+		return abi, nil
+	}
+
+	var cs *state.Contract
+	err := d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		cs, err = cli.GetContractStateByHash(contract)
+		return err
+	})
+	switch {
+	case err == nil:
+		abi = &cs.Manifest.ABI
+	case permanent(err):
+		abi = nil
+	default:
+		return nil, fmt.Errorf("cannot fetch contract %s: %w", contract.StringLE(), err)
+	}
+
+	d.contractsMu.Lock()
+	if d.contracts == nil {
+		d.contracts = make(map[util.Uint160]*manifest.ABI)
+	}
+	d.contracts[contract] = abi
+	d.contractsMu.Unlock()
+
+	return abi, nil
+}
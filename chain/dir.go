@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// Directory of exported files contains
+//
+//	blocks/<index>.bin  blocks in neo-go binary format
+//	logs/<hash>.json    application logs in JSON format of getapplicationlog
+//
+// where index is a decimal block index and hash is a transaction or block
+// hash in little endian hex, the same way RPC nodes print them.
+const (
+	dirBlocks    = "blocks"
+	dirLogs      = "logs"
+	blockFileExt = ".bin"
+	logFileExt   = ".json"
+)
+
+type dirStorage struct {
+	dir string
+}
+
+// NewDirStorage returns read-only storage of blocks and application logs
+// exported to the directory. Files missing in the directory are treated as
+// not stored data.
+func NewDirStorage(dir string) (Storage, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("storage dir [%s] init: %w", dir, err)
+	}
+
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("storage dir [%s] init: not a directory", dir)
+	}
+
+	return &dirStorage{dir: dir}, nil
+}
+
+func (s *dirStorage) Block(index uint32) (*block.Block, error) {
+	data, err := s.read(dirBlocks, strconv.FormatUint(uint64(index), 10)+blockFileExt)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	// exported blocks do not tell whether the network keeps state root in
+	// the header
+	res, err := unmarshalBlock(data, false)
+	if err != nil {
+		res, err = unmarshalBlock(data, true)
+	}
+
+	return res, err
+}
+
+func (s *dirStorage) PutBlock(*block.Block) error {
+	return ErrReadOnly
+}
+
+func (s *dirStorage) ApplicationLog(h util.Uint256) (*result.ApplicationLog, error) {
+	data, err := s.read(dirLogs, h.StringLE()+logFileExt)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	res := new(result.ApplicationLog)
+	return res, res.UnmarshalJSON(data)
+}
+
+func (s *dirStorage) PutApplicationLog(util.Uint256, *result.ApplicationLog) error {
+	return ErrReadOnly
+}
+
+func (s *dirStorage) Close() error {
+	return nil
+}
+
+// read returns contents of the file in the storage dir or nil if there is
+// no such file.
+func (s *dirStorage) read(subdir, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, subdir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	return data, err
+}
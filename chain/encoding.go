@@ -0,0 +1,341 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"github.com/nspcc-dev/neo-go/pkg/vm/vmstate"
+	"go.etcd.io/bbolt"
+)
+
+// Records of blocks and logs buckets start with the encoding byte followed
+// by neo-go binary serialization of the block or application log, which is
+// compressed with zstd if the cache is compressed. Legacy records have no
+// encoding byte: blocks are stored in binary and start with zero block
+// version, application logs are stored in JSON and start with '{'.
+const (
+	encodingPlain byte = 0xb0
+	encodingZstd  byte = 0xb1
+)
+
+var compressionKey = []byte("compression")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	zstdOnce.Do(func() {
+		// encoder and decoder without readers and writers never fail
+		zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+}
+
+// encodeRecord wraps serialized block or application log into the record.
+func encodeRecord(data []byte, compress bool) []byte {
+	if !compress {
+		return append([]byte{encodingPlain}, data...)
+	}
+
+	initZstd()
+	return zstdEncoder.EncodeAll(data, []byte{encodingZstd})
+}
+
+// decodeRecord returns serialized block or application log of the record.
+// Legacy records are returned as is.
+func decodeRecord(v []byte) (data []byte, legacy bool, err error) {
+	if len(v) == 0 {
+		return nil, false, errors.New("empty record")
+	}
+
+	switch v[0] {
+	case encodingPlain:
+		return v[1:], false, nil
+	case encodingZstd:
+		initZstd()
+		data, err = zstdDecoder.DecodeAll(v[1:], nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("zstd: %w", err)
+		}
+		return data, false, nil
+	default:
+		return v, true, nil
+	}
+}
+
+// compressed returns true if new records of the cache are compressed.
+func compressed(tx *bbolt.Tx) bool {
+	bkt := tx.Bucket(metaBucket)
+	if bkt == nil {
+		return false
+	}
+
+	v := bkt.Get(compressionKey)
+	return len(v) == 1 && v[0] == encodingZstd
+}
+
+func setCompression(tx *bbolt.Tx, compress bool) error {
+	bkt, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	if !compress {
+		return bkt.Delete(compressionKey)
+	}
+
+	return bkt.Put(compressionKey, []byte{encodingZstd})
+}
+
+func encodeBlock(b *block.Block, compress bool) ([]byte, error) {
+	w := io.NewBufBinWriter()
+	b.EncodeBinary(w.BinWriter)
+	if w.Err != nil {
+		return nil, w.Err
+	}
+
+	return encodeRecord(w.Bytes(), compress), nil
+}
+
+// decodeAnyBlock decodes block without knowing whether the network keeps
+// state root in the header.
+func decodeAnyBlock(v []byte) (*block.Block, error) {
+	b, err := decodeBlock(v, false)
+	if err == nil {
+		return b, nil
+	}
+
+	return decodeBlock(v, true)
+}
+
+func decodeBlock(v []byte, stateRoot bool) (*block.Block, error) {
+	data, _, err := decodeRecord(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalBlock(data, stateRoot)
+}
+
+// unmarshalBlock decodes block serialized in neo-go binary format.
+func unmarshalBlock(data []byte, stateRoot bool) (*block.Block, error) {
+	res := block.New(stateRoot)
+	r := io.NewBinReaderFromBuf(data)
+	res.DecodeBinary(r)
+	if r.Err == nil && r.Len() != 0 {
+		r.Err = errors.New("unexpected trailing data")
+	}
+
+	return res, r.Err
+}
+
+func encodeHeader(h *block.Header, compress bool) ([]byte, error) {
+	w := io.NewBufBinWriter()
+	h.EncodeBinary(w.BinWriter)
+	if w.Err != nil {
+		return nil, w.Err
+	}
+
+	return encodeRecord(w.Bytes(), compress), nil
+}
+
+func decodeHeader(v []byte, stateRoot bool) (*block.Header, error) {
+	data, _, err := decodeRecord(v)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &block.Header{StateRootEnabled: stateRoot}
+	r := io.NewBinReaderFromBuf(data)
+	res.DecodeBinary(r)
+	if r.Err == nil && r.Len() != 0 {
+		r.Err = errors.New("unexpected trailing data")
+	}
+
+	return res, r.Err
+}
+
+// encodeLog serializes application log in binary the same way neo-go
+// stores execution results. Result stack items which can not be serialized,
+// e.g. interop interfaces, are stored as invalid items. Notification items
+// are serialized without invalid items, it never fails for notifications
+// accepted by the VM.
+func encodeLog(appLog *result.ApplicationLog, compress bool) ([]byte, error) {
+	w := io.NewBufBinWriter()
+	sc := stackitem.NewSerializationContext()
+
+	w.WriteBytes(appLog.Container[:])
+	w.WriteBool(appLog.IsTransaction)
+	w.WriteVarUint(uint64(len(appLog.Executions)))
+	for _, e := range appLog.Executions {
+		w.WriteB(byte(e.Trigger))
+		w.WriteB(byte(e.VMState))
+		w.WriteU64LE(uint64(e.GasConsumed))
+		w.WriteVarUint(uint64(len(e.Stack)))
+		for _, it := range e.Stack {
+			data, err := sc.Serialize(it, true)
+			if err != nil {
+				return nil, err
+			}
+			w.WriteBytes(data)
+		}
+		w.WriteVarUint(uint64(len(e.Events)))
+		for i := range e.Events {
+			e.Events[i].EncodeBinaryWithContext(w.BinWriter, sc)
+		}
+		w.WriteString(e.FaultException)
+	}
+
+	if w.Err != nil {
+		return nil, w.Err
+	}
+
+	return encodeRecord(w.Bytes(), compress), nil
+}
+
+func decodeLog(v []byte) (*result.ApplicationLog, error) {
+	data, legacy, err := decodeRecord(v)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(result.ApplicationLog)
+	if legacy {
+		return res, res.UnmarshalJSON(data)
+	}
+
+	r := io.NewBinReaderFromBuf(data)
+	r.ReadBytes(res.Container[:])
+	res.IsTransaction = r.ReadBool()
+	n := r.ReadVarUint()
+	if r.Err == nil && n > stackitem.MaxDeserialized {
+		return nil, errors.New("invalid amount of executions")
+	}
+
+	for i := uint64(0); i < n && r.Err == nil; i++ {
+		var e state.Execution
+
+		e.Trigger = trigger.Type(r.ReadB())
+		e.VMState = vmstate.State(r.ReadB())
+		e.GasConsumed = int64(r.ReadU64LE())
+		sz := r.ReadVarUint()
+		if r.Err == nil && sz > stackitem.MaxDeserialized {
+			return nil, errors.New("invalid stack size")
+		}
+		for j := uint64(0); j < sz && r.Err == nil; j++ {
+			e.Stack = append(e.Stack, stackitem.DecodeBinaryProtected(r))
+		}
+		r.ReadArray(&e.Events)
+		e.FaultException = r.ReadString()
+
+		res.Executions = append(res.Executions, e)
+	}
+
+	if r.Err == nil && r.Len() != 0 {
+		r.Err = errors.New("unexpected trailing data")
+	}
+
+	return res, r.Err
+}
+
+// reencodeBlockRecord stores block record with the encoding of the cache.
+func reencodeBlockRecord(tx *bbolt.Tx, k, v []byte) error {
+	b, err := decodeAnyBlock(v)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeBlock(b, compressed(tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(blocksBucket).Put(k, data)
+}
+
+// reencodeHeaderRecord stores header record with the encoding of the cache.
+func reencodeHeaderRecord(tx *bbolt.Tx, k, v []byte) error {
+	h, err := decodeHeader(v, false)
+	if err != nil {
+		h, err = decodeHeader(v, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeHeader(h, compressed(tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(headersBucket).Put(k, data)
+}
+
+// reencodeLogRecord stores application log record with the encoding of the
+// cache.
+func reencodeLogRecord(tx *bbolt.Tx, k, v []byte) error {
+	appLog, err := decodeLog(v)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeLog(appLog, compressed(tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(logsBucket).Put(k, data)
+}
+
+// SetCompression enables or disables zstd compression of blocks and
+// application logs in the cache and re-encodes existing records. Space of
+// the records is reclaimed after compaction.
+func SetCompression(dbPath string, compress bool, progress MigrationProgress) error {
+	db, err := openDB(dbPath, false, maintenanceLockTimeout, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = migrate(db, progress)
+	if err != nil {
+		return fmt.Errorf("database [%s] schema upgrade: %w", dbPath, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if err := setCompression(tx, compress); err != nil {
+			return err
+		}
+		// records are re-encoded from scratch, they are decoded regardless
+		// of the encoding anyway
+		return tx.Bucket(metaBucket).Delete(migrationKey)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot set compression of database [%s]: %w", dbPath, err)
+	}
+
+	reencode := []migration{
+		{version: SchemaVersion, name: "application logs", bucket: logsBucket, update: reencodeLogRecord},
+		{version: SchemaVersion, name: "blocks", bucket: blocksBucket, update: reencodeBlockRecord},
+		{version: SchemaVersion, name: "headers", bucket: headersBucket, update: reencodeHeaderRecord},
+	}
+
+	for _, m := range reencode {
+		if err = m.run(db, progress); err != nil {
+			return fmt.Errorf("cannot re-encode %s of database [%s]: %w", m.name, dbPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"go.etcd.io/bbolt"
+)
+
+// applicationLogs returns application logs in testdata in JSON format of
+// getapplicationlog RPC method by file names.
+func applicationLogs(t *testing.T) map[string][]byte {
+	files, err := filepath.Glob(filepath.Join("testdata", "applogs", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no application logs in testdata")
+	}
+
+	res := make(map[string][]byte, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[filepath.Base(f)] = data
+	}
+
+	return res
+}
+
+// requireSameLog compares application logs by their JSON, stack items have
+// no other comparison.
+func requireSameLog(t *testing.T, expected, actual *result.ApplicationLog) {
+	t.Helper()
+
+	exp, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act, err := json.Marshal(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(exp, act) {
+		t.Fatalf("application log mismatch\nexpected: %s\nactual:   %s", exp, act)
+	}
+}
+
+func TestLogRoundTrip(t *testing.T) {
+	for name, data := range applicationLogs(t) {
+		for _, compress := range []bool{false, true} {
+			appLog := new(result.ApplicationLog)
+			if err := json.Unmarshal(data, appLog); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			// neo-go does not restore the flag from JSON
+			appLog.IsTransaction = bytes.Contains(data, []byte(`"txid"`))
+
+			v, err := encodeLog(appLog, compress)
+			if err != nil {
+				t.Fatalf("%s: encode: %v", name, err)
+			}
+
+			encoding := encodingPlain
+			if compress {
+				encoding = encodingZstd
+			}
+			if v[0] != encoding {
+				t.Fatalf("%s: unexpected encoding %x", name, v[0])
+			}
+
+			res, err := decodeLog(v)
+			if err != nil {
+				t.Fatalf("%s: decode: %v", name, err)
+			}
+
+			requireSameLog(t, appLog, res)
+
+			if res.IsTransaction != appLog.IsTransaction {
+				t.Fatalf("%s: transaction flag mismatch", name)
+			}
+		}
+	}
+}
+
+func TestLegacyLog(t *testing.T) {
+	for name, data := range applicationLogs(t) {
+		expected := new(result.ApplicationLog)
+		if err := json.Unmarshal(data, expected); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// legacy records are stored as neo-go marshals application logs
+		legacy, err := json.Marshal(expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := decodeLog(legacy)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		requireSameLog(t, expected, res)
+	}
+}
+
+func TestReencodeLogRecord(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logs := applicationLogs(t)
+
+	for _, compress := range []bool{false, true} {
+		err = db.Update(func(tx *bbolt.Tx) error {
+			if err := setCompression(tx, compress); err != nil {
+				return err
+			}
+
+			bkt, err := tx.CreateBucketIfNotExists(logsBucket)
+			if err != nil {
+				return err
+			}
+
+			for name, data := range logs {
+				appLog := new(result.ApplicationLog)
+				if err := json.Unmarshal(data, appLog); err != nil {
+					return err
+				}
+
+				legacy, err := json.Marshal(appLog)
+				if err != nil {
+					return err
+				}
+
+				key := []byte(name)
+				if err = bkt.Put(key, legacy); err != nil {
+					return err
+				}
+
+				if err = reencodeLogRecord(tx, key, legacy); err != nil {
+					return err
+				}
+
+				v := bkt.Get(key)
+				if _, isLegacy, err := decodeRecord(v); err != nil || isLegacy {
+					t.Fatalf("%s: record is not re-encoded: %v", name, err)
+				}
+
+				res, err := decodeLog(v)
+				if err != nil {
+					return err
+				}
+
+				requireSameLog(t, appLog, res)
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
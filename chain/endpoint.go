@@ -0,0 +1,382 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// EndpointStat contains statistics of requests to the RPC endpoint.
+type EndpointStat struct {
+	Address  string
+	Requests uint64
+	Failures uint64
+	// Latency is an average duration of successful requests.
+	Latency time.Duration
+	// Err is the last error returned by the endpoint.
+	Err error
+}
+
+// endpoint is an RPC node with health tracking. Endpoint is considered down
+// after a few failures in a row and it is not used for a while unless every
+// other endpoint is down too.
+type endpoint struct {
+	address string
+	client  *rpcclient.Client
+
+	mu        sync.Mutex
+	requests  uint64
+	failures  uint64
+	failed    int // failures in a row
+	downUntil time.Time
+	latency   time.Duration // moving average of successful requests
+	err       error
+
+	// inflight is the amount of running requests, guarded by the
+	// scheduler mutex of the chain
+	inflight int
+}
+
+const (
+	endpointFailureLimit = 3
+	endpointDownTime     = 30 * time.Second
+
+	// DefaultEndpointWorkers is the default amount of parallel requests
+	// to every endpoint.
+	DefaultEndpointWorkers = 3
+)
+
+// dialEndpoints connects to the RPC endpoints and checks that all of them
+// serve the same chain. Endpoints that fail health check are kept to be
+// shown in the statistics, but they are never used.
+func dialEndpoints(ctx context.Context, addresses []string, opts rpcclient.Options, retry retryPolicy) ([]*endpoint, *result.Version, util.Uint256, error) {
+	var (
+		res     []*endpoint
+		version *result.Version
+		genesis util.Uint256
+		healthy int
+	)
+
+	for _, address := range addresses {
+		e := &endpoint{address: address}
+		res = append(res, e)
+
+		v, h, err := e.dial(ctx, opts, retry)
+		if err != nil {
+			if e.client != nil {
+				e.client.Close()
+				e.client = nil
+			}
+			e.fail(err)
+			continue
+		}
+
+		if healthy != 0 && (v.Protocol.Network != version.Protocol.Network || !h.Equals(genesis)) {
+			for _, e := range res {
+				if e.client != nil {
+					e.client.Close()
+				}
+			}
+			return nil, nil, util.Uint256{}, fmt.Errorf("endpoint %s serves network %d with genesis %s, expected network %d with genesis %s",
+				address, v.Protocol.Network, h.StringLE(), version.Protocol.Network, genesis.StringLE())
+		}
+
+		version, genesis = v, h
+		healthy++
+	}
+
+	if healthy == 0 {
+		errs := make([]string, 0, len(res))
+		for _, e := range res {
+			errs = append(errs, fmt.Sprintf("%s: %s", e.address, e.err))
+		}
+		return nil, nil, util.Uint256{}, fmt.Errorf("no healthy rpc endpoints: %v", errs)
+	}
+
+	return res, version, genesis, nil
+}
+
+func (e *endpoint) dial(ctx context.Context, opts rpcclient.Options, retry retryPolicy) (*result.Version, util.Uint256, error) {
+	var (
+		v       *result.Version
+		genesis util.Uint256
+		err     error
+	)
+
+	e.client, err = rpcclient.New(ctx, e.address, opts)
+	if err != nil {
+		return nil, util.Uint256{}, fmt.Errorf("rpc connection: %w", err)
+	}
+
+	err = retry.do(ctx, e.client.Init)
+	if err != nil {
+		return nil, util.Uint256{}, fmt.Errorf("rpc client initialization: %w", err)
+	}
+
+	err = retry.do(ctx, func() (err error) {
+		v, err = e.client.GetVersion()
+		return err
+	})
+	if err != nil {
+		return nil, util.Uint256{}, fmt.Errorf("rpc get version: %w", err)
+	}
+
+	err = retry.do(ctx, func() (err error) {
+		genesis, err = e.client.GetBlockHash(0)
+		return err
+	})
+	if err != nil {
+		return nil, util.Uint256{}, fmt.Errorf("rpc get genesis block hash: %w", err)
+	}
+
+	return v, genesis, nil
+}
+
+func (e *endpoint) down(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return now.Before(e.downUntil)
+}
+
+func (e *endpoint) do(f func(*rpcclient.Client) error) error {
+	e.mu.Lock()
+	e.requests++
+	e.mu.Unlock()
+
+	start := time.Now()
+
+	err := f(e.client)
+	if err != nil {
+		// node is healthy if it responds with an error
+		if !permanent(err) {
+			e.fail(err)
+		}
+		return fmt.Errorf("%s: %w", e.address, err)
+	}
+
+	e.mu.Lock()
+	e.failed = 0
+	if e.latency == 0 {
+		e.latency = time.Since(start)
+	} else {
+		e.latency = (4*e.latency + time.Since(start)) / 5
+	}
+	e.mu.Unlock()
+
+	return nil
+}
+
+// score returns expected duration of the request to the endpoint with
+// its current load. Endpoints without measured latency get zero score, so
+// every endpoint is probed first.
+func (e *endpoint) score() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.latency * time.Duration(e.inflight+1)
+}
+
+func (e *endpoint) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures++
+	e.failed++
+	e.err = err
+	if e.failed >= endpointFailureLimit {
+		e.downUntil = time.Now().Add(endpointDownTime)
+	}
+}
+
+func (e *endpoint) stat() EndpointStat {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return EndpointStat{
+		Address:  e.address,
+		Requests: e.requests,
+		Failures: e.failures,
+		Latency:  e.latency,
+		Err:      e.err,
+	}
+}
+
+// call performs the request with the endpoint which is expected to answer
+// faster than others with respect to its measured latency and current load.
+// Every endpoint runs a limited amount of parallel requests, so call waits
+// for a free endpoint. On failure the request is repeated with the next
+// endpoint. Endpoints which are down are used only when every other
+// endpoint fails. When every endpoint fails, the request is retried after
+// a growing delay. Permanent errors such as unknown block are not retried.
+//
+// RPC client does not support cancellation of running requests, so call
+// returns as soon as the context is cancelled and the request is finished
+// in background within request timeout. Values set by f must not be used
+// if call returns an error.
+func (d *Chain) call(ctx context.Context, f func(*rpcclient.Client) error) error {
+	if d.Offline() {
+		return ErrNotCached
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := d.retry.do(ctx, func() error {
+		return d.callEndpoints(ctx, f)
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// callEndpoints performs the request with every endpoint until the first
+// success.
+func (d *Chain) callEndpoints(ctx context.Context, f func(*rpcclient.Client) error) error {
+	var err error
+
+	tried := make(map[*endpoint]bool, len(d.endpoints))
+	for {
+		e, ctxErr := d.acquire(ctx, tried)
+		if ctxErr != nil {
+			return ctxErr
+		}
+		if e == nil {
+			return err
+		}
+
+		if ctxErr = d.limiter.wait(ctx); ctxErr != nil {
+			d.release(e)
+			return ctxErr
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			err := e.do(f)
+			d.release(e)
+			done <- err
+		}()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err = <-done:
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		tried[e] = true
+	}
+}
+
+// acquire waits for the endpoint with a free slot and reserves it. Returns
+// nil if every endpoint is already tried.
+func (d *Chain) acquire(ctx context.Context, tried map[*endpoint]bool) (*endpoint, error) {
+	d.schedMu.Lock()
+	defer d.schedMu.Unlock()
+
+	for {
+		var (
+			up, down   bool // untried endpoints exist
+			best, slow *endpoint
+			bestScore  time.Duration
+			slowScore  time.Duration
+		)
+
+		now := time.Now()
+		for _, e := range d.endpoints {
+			if e.client == nil || tried[e] {
+				continue
+			}
+
+			isDown := e.down(now)
+			if isDown {
+				down = true
+			} else {
+				up = true
+			}
+
+			if e.inflight >= d.workers {
+				continue
+			}
+
+			score := e.score()
+			switch {
+			case !isDown && (best == nil || score < bestScore):
+				best, bestScore = e, score
+			case isDown && (slow == nil || score < slowScore):
+				slow, slowScore = e, score
+			}
+		}
+
+		switch {
+		case !up && !down:
+			return nil, nil
+		case best == nil && !up:
+			// use endpoints which are down only if there are no others
+			best = slow
+		}
+
+		if best != nil {
+			best.inflight++
+			return best, nil
+		}
+
+		// wait for any released slot
+		released := d.released
+		d.schedMu.Unlock()
+		select {
+		case <-ctx.Done():
+			d.schedMu.Lock()
+			return nil, ctx.Err()
+		case <-released:
+		}
+		d.schedMu.Lock()
+	}
+}
+
+func (d *Chain) release(e *endpoint) {
+	d.schedMu.Lock()
+	defer d.schedMu.Unlock()
+
+	e.inflight--
+	close(d.released)
+	d.released = make(chan struct{})
+}
+
+// Workers returns the amount of parallel requests which keeps every
+// healthy endpoint busy.
+func (d *Chain) Workers() int {
+	var healthy int
+	for _, e := range d.endpoints {
+		if e.client != nil {
+			healthy++
+		}
+	}
+
+	if healthy == 0 {
+		return d.workers
+	}
+
+	return d.workers * healthy
+}
+
+// Endpoints returns statistics of every RPC endpoint.
+func (d *Chain) Endpoints() []EndpointStat {
+	res := make([]EndpointStat, 0, len(d.endpoints))
+	for _, e := range d.endpoints {
+		res = append(res, e.stat())
+	}
+
+	return res
+}
@@ -0,0 +1,262 @@
+package chain
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// Cache archive is a tar file compressed with gzip. It contains
+//
+//	meta.json           chain identity and the range of exported blocks
+//	blocks/<index>.bin  blocks in neo-go binary format
+//	logs/<hash>.json    application logs in JSON format of getapplicationlog
+//
+// Metadata is the first file of the archive, every block is followed by
+// cached application logs of the block and its transactions. Extracted
+// archive is a directory of NewDirStorage.
+const (
+	archiveMetaFile = "meta.json"
+	archiveVersion  = 1
+)
+
+type archiveMeta struct {
+	Version    int          `json:"version"`
+	Genesis    util.Uint256 `json:"genesis"`
+	Magic      uint32       `json:"magic"`
+	StateRoot  bool         `json:"stateroot"`
+	MsPerBlock uint32       `json:"msperblock"`
+	// From and To define [From, To) interval of exported blocks.
+	From uint32 `json:"from"`
+	To   uint32 `json:"to"`
+}
+
+func (m archiveMeta) identity() Identity {
+	return Identity{
+		Genesis:              m.Genesis,
+		Magic:                m.Magic,
+		StateRootInHeader:    m.StateRoot,
+		MillisecondsPerBlock: m.MsPerBlock,
+	}
+}
+
+// ExportDump writes blocks in [from, to) interval of the cache stored in
+// dbPath to w in the format of `neo-go db dump`. Interval is limited by
+// the highest cached block, every block of it must be cached. Dumps which
+// do not start with genesis block are incremental, they start with the
+// index of the first block. Progress is called after every block.
+func ExportDump(dbPath string, w io.Writer, from, to uint32, progress func(done, total int)) (int, error) {
+	db, err := openCache(dbPath, true, Options{LockTimeout: maintenanceLockTimeout})
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var count int
+	err = db.View(func(tx *bbolt.Tx) error {
+		indices := cachedIndices(tx, from, to)
+		if len(indices) == 0 {
+			return errors.New("no cached blocks in the range")
+		}
+
+		for i, index := range indices {
+			if expected := from + uint32(i); index != expected {
+				return fmt.Errorf("block %d is not cached, dump requires contiguous range", expected)
+			}
+		}
+
+		bw := bufio.NewWriterSize(w, dumpBufferSize)
+		num := make([]byte, 4)
+		writeNum := func(v uint32) {
+			binary.LittleEndian.PutUint32(num, v)
+			_, _ = bw.Write(num)
+		}
+
+		if from != 0 {
+			writeNum(from)
+		}
+		writeNum(uint32(len(indices)))
+
+		bkt := tx.Bucket(blocksBucket)
+		for i, index := range indices {
+			data, _, err := decodeRecord(bkt.Get(blockKey(index)))
+			if err != nil {
+				return fmt.Errorf("cannot decode block %d: %w", index, err)
+			}
+
+			writeNum(uint32(len(data)))
+			if _, err = bw.Write(data); err != nil {
+				return err
+			}
+
+			count++
+			if progress != nil {
+				progress(i+1, len(indices))
+			}
+		}
+
+		return bw.Flush()
+	})
+	if err != nil {
+		return count, fmt.Errorf("cannot export blocks of database [%s]: %w", dbPath, err)
+	}
+
+	return count, nil
+}
+
+// ExportArchive writes cached blocks in [from, to) interval of the cache
+// stored in dbPath and their cached application logs to w as a cache
+// archive, see ImportArchive. Progress is called after every block.
+func ExportArchive(dbPath string, w io.Writer, from, to uint32, progress func(done, total int)) (blocks, logs int, err error) {
+	db, err := openCache(dbPath, true, Options{LockTimeout: maintenanceLockTimeout})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+
+	writeFile := func(name string, data []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     int64(len(data)),
+			Mode:     0644,
+			ModTime:  now,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(data)
+		return err
+	}
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		id, err := readIdentity(tx)
+		if err != nil {
+			return err
+		}
+
+		if id == nil {
+			return errors.New("cache has no chain identity, open it with RPC node once")
+		}
+
+		indices := cachedIndices(tx, from, to)
+		if len(indices) == 0 {
+			return errors.New("no cached blocks in the range")
+		}
+
+		meta, err := json.Marshal(archiveMeta{
+			Version:    archiveVersion,
+			Genesis:    id.Genesis,
+			Magic:      id.Magic,
+			StateRoot:  id.StateRootInHeader,
+			MsPerBlock: id.MillisecondsPerBlock,
+			From:       indices[0],
+			To:         indices[len(indices)-1] + 1,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = writeFile(archiveMetaFile, meta); err != nil {
+			return err
+		}
+
+		blocksBkt, logsBkt := tx.Bucket(blocksBucket), tx.Bucket(logsBucket)
+		for i, index := range indices {
+			data, _, err := decodeRecord(blocksBkt.Get(blockKey(index)))
+			if err != nil {
+				return fmt.Errorf("cannot decode block %d: %w", index, err)
+			}
+
+			b, err := unmarshalBlock(data, id.StateRootInHeader)
+			if err != nil {
+				return fmt.Errorf("cannot decode block %d: %w", index, err)
+			}
+
+			name := path.Join(dirBlocks, strconv.FormatUint(uint64(index), 10)+blockFileExt)
+			if err = writeFile(name, data); err != nil {
+				return err
+			}
+			blocks++
+
+			for _, h := range containers(b) {
+				if logsBkt == nil {
+					break
+				}
+
+				v := logsBkt.Get(h.BytesLE())
+				if v == nil {
+					continue
+				}
+
+				appLog, err := decodeLog(v)
+				if err != nil {
+					return fmt.Errorf("cannot decode application log of %s: %w", h.StringLE(), err)
+				}
+
+				data, err := json.Marshal(appLog)
+				if err != nil {
+					return fmt.Errorf("cannot encode application log of %s: %w", h.StringLE(), err)
+				}
+
+				if err = writeFile(path.Join(dirLogs, h.StringLE()+logFileExt), data); err != nil {
+					return err
+				}
+				logs++
+			}
+
+			if progress != nil {
+				progress(i+1, len(indices))
+			}
+		}
+
+		if err = tw.Close(); err != nil {
+			return err
+		}
+
+		return gw.Close()
+	})
+	if err != nil {
+		return blocks, logs, fmt.Errorf("cannot export blocks of database [%s]: %w", dbPath, err)
+	}
+
+	return blocks, logs, nil
+}
+
+// cachedIndices returns ordered indices of cached blocks in [from, to)
+// interval.
+func cachedIndices(tx *bbolt.Tx, from, to uint32) []uint32 {
+	bkt := tx.Bucket(blocksBucket)
+	if bkt == nil {
+		return nil
+	}
+
+	var res []uint32
+	_ = bkt.ForEach(func(k, _ []byte) error {
+		if index := binary.LittleEndian.Uint32(k); index >= from && index < to {
+			res = append(res, index)
+		}
+		return nil
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res
+}
@@ -0,0 +1,236 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc"
+	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+)
+
+const (
+	// followPollInterval is the interval of block count requests when new
+	// blocks are not pushed by RPC nodes.
+	followPollInterval = time.Second
+
+	// wsDialTimeout limits connection to WebSocket endpoint of RPC node.
+	wsDialTimeout = 5 * time.Second
+)
+
+// follower keeps the height of the chain and blocks pushed by RPC node.
+type follower struct {
+	mu      sync.Mutex
+	height  uint32
+	pushed  map[uint32]*block.Block
+	polling bool
+	// wake is signaled when the height is changed or pushing is stopped
+	wake chan struct{}
+}
+
+// Follow calls handle for every block of the chain starting with the block
+// with the specified index. It waits for new blocks until the context is
+// canceled or handle fails. New blocks are pushed by the first RPC node
+// which accepts WebSocket subscription, otherwise block count is polled.
+// Pushed blocks are verified and cached the same way as blocks returned
+// by Block.
+func (d *Chain) Follow(ctx context.Context, from uint32, handle func(b *block.Block) error) error {
+	if d.Offline() {
+		return errors.New("new blocks can not be followed in offline mode")
+	}
+
+	f := &follower{
+		height:  from,
+		pushed:  make(map[uint32]*block.Block),
+		polling: true,
+		wake:    make(chan struct{}, 1),
+	}
+
+	ws := d.subscribe(ctx)
+	if ws != nil {
+		defer ws.Close()
+
+		f.polling = false
+		go f.receive(ws)
+	}
+
+	for next := from; ; {
+		height, err := d.waitHeight(ctx, f, next)
+		if err != nil {
+			return err
+		}
+
+		for ; next < height; next++ {
+			b, err := d.followed(ctx, f, next)
+			if err != nil {
+				return err
+			}
+
+			if err = handle(b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// subscribe returns WebSocket client of the first endpoint which accepts
+// subscription for new blocks or nil if there is none.
+func (d *Chain) subscribe(ctx context.Context) *rpcclient.WSClient {
+	for _, e := range d.endpoints {
+		if e.client == nil {
+			continue
+		}
+
+		address, ok := wsAddress(e.address)
+		if !ok {
+			continue
+		}
+
+		ws, err := rpcclient.NewWS(ctx, address, rpcclient.Options{DialTimeout: wsDialTimeout})
+		if err != nil {
+			continue
+		}
+
+		if err = ws.Init(); err == nil {
+			_, err = ws.SubscribeForNewBlocks(nil)
+		}
+		if err != nil {
+			ws.Close()
+			continue
+		}
+
+		return ws
+	}
+
+	return nil
+}
+
+// wsAddress returns address of WebSocket endpoint of neo-go RPC node.
+func wsAddress(address string) (string, bool) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", false
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+		return address, true
+	default:
+		return "", false
+	}
+
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/ws"
+	}
+
+	return u.String(), true
+}
+
+// receive stores blocks pushed by RPC node. Block count is polled when
+// the connection is closed.
+func (f *follower) receive(ws *rpcclient.WSClient) {
+	for n := range ws.Notifications {
+		b, ok := n.Value.(*block.Block)
+		if n.Type != neorpc.BlockEventID || !ok {
+			continue
+		}
+
+		f.mu.Lock()
+		f.pushed[b.Index] = b
+		if b.Index >= f.height {
+			f.height = b.Index + 1
+		}
+		f.mu.Unlock()
+
+		f.signal()
+	}
+
+	f.mu.Lock()
+	f.polling = true
+	f.mu.Unlock()
+
+	f.signal()
+}
+
+func (f *follower) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// waitHeight returns the height of the chain once it exceeds the index of
+// the next block.
+func (d *Chain) waitHeight(ctx context.Context, f *follower, next uint32) (uint32, error) {
+	for {
+		f.mu.Lock()
+		height, polling := f.height, f.polling
+		f.mu.Unlock()
+
+		if height > next {
+			return height, nil
+		}
+
+		if !polling {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-f.wake:
+			}
+			continue
+		}
+
+		count, err := d.BlockCount(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		if count > next {
+			return count, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-f.wake:
+		case <-time.After(followPollInterval):
+		}
+	}
+}
+
+// followed returns the block pushed by RPC node or fetches it.
+func (d *Chain) followed(ctx context.Context, f *follower, i uint32) (*block.Block, error) {
+	f.mu.Lock()
+	b := f.pushed[i]
+	for index := range f.pushed {
+		if index <= i {
+			delete(f.pushed, index)
+		}
+	}
+	f.mu.Unlock()
+
+	if b == nil {
+		return d.Block(ctx, i)
+	}
+
+	cached, err := d.block(i)
+	if err != nil || cached != nil {
+		return cached, err
+	}
+
+	if d.verifying {
+		if err = d.verify(ctx, b, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, d.addBlock(b)
+}
@@ -0,0 +1,203 @@
+package chain
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"go.etcd.io/bbolt"
+)
+
+// Headers of blocks fetched without transactions are cached in a separate
+// bucket with little endian block index keys like keys of blocks bucket.
+// Headers of cached blocks are taken from the blocks bucket.
+var headersBucket = []byte("headers")
+
+// Header returns header of the block. It is much cheaper than Block for
+// timing analyses: headers are fetched without transactions and application
+// logs. Headers are available only in bbolt caches, so with other storages
+// headers of blocks missing in the storage are fetched every time.
+func (d *Chain) Header(ctx context.Context, i uint32) (*block.Header, error) {
+	cached, err := d.header(i)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		return cached, nil
+	}
+
+	if d.Offline() {
+		return nil, fmt.Errorf("%w: header %d", ErrNotCached, i)
+	}
+
+	header, err := d.fetchHeader(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.verifying {
+		if header.Index != i {
+			return nil, fmt.Errorf("%w %d: node returned header %d", ErrInvalidBlock, i, header.Index)
+		}
+
+		err = d.verifyHeader(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return header, d.addHeader(header)
+}
+
+func (d *Chain) fetchHeader(ctx context.Context, i uint32) (*block.Header, error) {
+	var header *block.Header
+	err := d.call(ctx, func(cli *rpcclient.Client) error {
+		h, err := cli.GetBlockHash(i)
+		if err != nil {
+			return err
+		}
+
+		res, err := cli.GetBlockHeader(h)
+		if err != nil {
+			return err
+		}
+
+		header = res
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("header %d fetch: %w", i, err)
+	}
+
+	return header, nil
+}
+
+// header returns header of the cached block or the cached header.
+func (d *Chain) header(i uint32) (*block.Header, error) {
+	b, err := d.block(i)
+	if err != nil {
+		return nil, err
+	}
+
+	if b != nil {
+		return &b.Header, nil
+	}
+
+	if d.bulk != nil {
+		if res := d.bulk.header(i); res != nil {
+			return res, nil
+		}
+	}
+
+	if d.db == nil {
+		return nil, nil
+	}
+
+	var res *block.Header
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(headersBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		data := bkt.Get(blockKey(i))
+		if len(data) == 0 {
+			return nil
+		}
+
+		res, err = decodeHeader(data, d.stateRoot)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header %d from cache: %w", i, err)
+	}
+
+	return res, nil
+}
+
+func (d *Chain) addHeader(h *block.Header) error {
+	if d.db == nil || d.db.IsReadOnly() {
+		return nil
+	}
+
+	var err error
+	if d.bulk != nil {
+		err = d.bulk.putHeader(h)
+	} else {
+		err = d.store.(*boltStorage).storeHeader(h)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot add header %d to cache: %w", h.Index, err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) storeHeader(h *block.Header) error {
+	data, err := encodeHeader(h, s.compress)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Batch(func(tx *bbolt.Tx) error {
+		return putHeader(tx, h.Index, data)
+	})
+}
+
+func putHeader(tx *bbolt.Tx, index uint32, data []byte) error {
+	bkt, err := tx.CreateBucketIfNotExists(headersBucket)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Put(blockKey(index), data)
+}
+
+// deleteHeader removes cached header of the block.
+func deleteHeader(tx *bbolt.Tx, index uint32) error {
+	bkt := tx.Bucket(headersBucket)
+	if bkt == nil {
+		return nil
+	}
+
+	return bkt.Delete(blockKey(index))
+}
+
+// deleteHeaders removes cached headers of blocks in [from, to) interval.
+func deleteHeaders(tx *bbolt.Tx, from, to uint32) error {
+	bkt := tx.Bucket(headersBucket)
+	if bkt == nil {
+		return nil
+	}
+
+	// keys are little endian, so headers of the interval are not adjacent
+	var keys [][]byte
+	err := bkt.ForEach(func(k, _ []byte) error {
+		if index := binary.LittleEndian.Uint32(k); index >= from && index < to {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err = bkt.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// blockKey returns key of the block in blocks and headers buckets.
+func blockKey(index uint32) []byte {
+	key := make([]byte, 4)
+	binary.LittleEndian.PutUint32(key, index)
+
+	return key
+}
@@ -0,0 +1,348 @@
+package chain
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// Chain dumps produced by `neo-go db dump` contain the amount of blocks
+// followed by blocks in neo-go binary format, each one prefixed with its
+// size. Incremental dumps start with the index of the first block. All
+// numbers are little endian uint32.
+const (
+	dumpBufferSize = 1 << 20
+
+	// maxDumpBlockSize is the maximum size of network message payload, no
+	// block can be larger.
+	maxDumpBlockSize = 32 << 20
+)
+
+// Import stores blocks of the chain dump read from r. Every block is
+// verified the same way as blocks fetched in verification mode, the first
+// block is checked against the cached or fetched previous one, so the dump
+// must belong to the chain. Cached blocks are not overwritten. Application
+// logs are not dumped, they are fetched when needed. Progress is called
+// after every block of the dump.
+func (d *Chain) Import(ctx context.Context, r io.Reader, progress func(done, total int)) (imported, skipped int, err error) {
+	br := bufio.NewReaderSize(r, dumpBufferSize)
+
+	start, count, incremental, err := readDumpHeader(br)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var prev *anchor
+	for i := 0; i < int(count); i++ {
+		if err = ctx.Err(); err != nil {
+			return imported, skipped, err
+		}
+
+		b, err := readDumpBlock(br, d.stateRoot)
+		if err != nil {
+			return imported, skipped, fmt.Errorf("cannot read block #%d of the dump: %w", i, err)
+		}
+
+		if i == 0 && incremental && b.Index != start {
+			return 0, 0, fmt.Errorf("dump starts at block %d, but the first block is %d", start, b.Index)
+		}
+
+		err = d.verify(ctx, b, prev)
+		if err != nil {
+			return imported, skipped, err
+		}
+
+		cached, err := d.block(b.Index)
+		if err != nil {
+			return imported, skipped, err
+		}
+
+		if cached != nil {
+			skipped++
+		} else {
+			if err = d.addBlock(b); err != nil {
+				return imported, skipped, err
+			}
+			imported++
+		}
+
+		prev = newAnchor(&b.Header)
+
+		if progress != nil {
+			progress(i+1, int(count))
+		}
+	}
+
+	return imported, skipped, nil
+}
+
+// readDumpHeader returns the amount of blocks in the dump and the index of
+// the first block of incremental dump. Blocks start with zero version, so
+// the third number of the dump is zero in regular dumps and it is the size
+// of the first block in incremental ones.
+func readDumpHeader(r *bufio.Reader) (start, count uint32, incremental bool, err error) {
+	hdr, err := r.Peek(12)
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(hdr) >= 4 && binary.LittleEndian.Uint32(hdr) == 0 {
+			return 0, 0, false, errors.New("dump contains no blocks")
+		}
+		return 0, 0, false, fmt.Errorf("cannot read dump header: %w", err)
+	}
+
+	if binary.LittleEndian.Uint32(hdr[8:]) != 0 {
+		start, count = binary.LittleEndian.Uint32(hdr), binary.LittleEndian.Uint32(hdr[4:])
+		_, err = r.Discard(8)
+		return start, count, true, err
+	}
+
+	count = binary.LittleEndian.Uint32(hdr)
+	_, err = r.Discard(4)
+
+	return 0, count, false, err
+}
+
+func readDumpBlock(r io.Reader, stateRoot bool) (*block.Block, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.LittleEndian.Uint32(size[:])
+	if n > maxDumpBlockSize {
+		return nil, fmt.Errorf("invalid block size %d", n)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return unmarshalBlock(data, stateRoot)
+}
+
+// archiveBlock is a block of the cache archive waiting to be stored.
+type archiveBlock struct {
+	block *block.Block
+	data  []byte
+}
+
+// archiveLog is an application log of the cache archive waiting to be
+// stored.
+type archiveLog struct {
+	hash util.Uint256
+	data []byte
+}
+
+// ImportArchive merges the cache archive produced by ExportArchive into the
+// cache of its network in dir. The cache is created if there is none, chain
+// identity of existing cache must match the archive. Cached blocks and
+// application logs are not overwritten. Blocks are indexed when all their
+// application logs are cached. Progress is called after every block of the
+// archive. Returns path to the cache and amount of stored blocks and
+// application logs.
+func ImportArchive(dir string, r io.Reader, progress func(done, total int)) (dbPath string, blocks, logs int, err error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("cannot read archive: %w", err)
+	}
+
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("cannot read archive: %w", err)
+	}
+
+	if hdr.Name != archiveMetaFile {
+		return "", 0, 0, fmt.Errorf("archive does not start with %s", archiveMetaFile)
+	}
+
+	var meta archiveMeta
+	if err = json.NewDecoder(tr).Decode(&meta); err != nil {
+		return "", 0, 0, fmt.Errorf("cannot decode archive metadata: %w", err)
+	}
+
+	if meta.Version != archiveVersion {
+		return "", 0, 0, fmt.Errorf("unsupported archive version %d", meta.Version)
+	}
+
+	id := meta.identity()
+	dbPath = CachePath(dir, id.Magic)
+
+	db, err := openCache(dbPath, false, Options{LockTimeout: maintenanceLockTimeout})
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer db.Close()
+
+	err = checkIdentity(db, id)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("database [%s] chain identity check: %w", dbPath, err)
+	}
+
+	var (
+		compress   bool
+		size       int
+		indices    []uint32
+		pendBlocks []archiveBlock
+		pendLogs   []archiveLog
+	)
+
+	_ = db.View(func(tx *bbolt.Tx) error {
+		compress = compressed(tx)
+		return nil
+	})
+
+	// flush stores pending records which are not cached yet
+	flush := func() error {
+		return db.Update(func(tx *bbolt.Tx) error {
+			for _, b := range pendBlocks {
+				if bkt := tx.Bucket(blocksBucket); bkt != nil && bkt.Get(blockKey(b.block.Index)) != nil {
+					continue
+				}
+				if err := putBlock(tx, b.block, b.data); err != nil {
+					return err
+				}
+				blocks++
+			}
+
+			for _, l := range pendLogs {
+				if bkt := tx.Bucket(logsBucket); bkt != nil && bkt.Get(l.hash.BytesLE()) != nil {
+					continue
+				}
+				if err := putLog(tx, l.hash, l.data); err != nil {
+					return err
+				}
+				logs++
+			}
+
+			size, pendBlocks, pendLogs = 0, nil, nil
+
+			return nil
+		})
+	}
+
+	for {
+		hdr, err = tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return dbPath, blocks, logs, fmt.Errorf("cannot read archive: %w", err)
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if hdr.Size > maxDumpBlockSize {
+			return dbPath, blocks, logs, fmt.Errorf("invalid size of archive file %s", hdr.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return dbPath, blocks, logs, fmt.Errorf("cannot read archive file %s: %w", hdr.Name, err)
+		}
+
+		subdir, name := path.Split(hdr.Name)
+		switch path.Clean(subdir) {
+		case dirBlocks:
+			b, err := unmarshalBlock(data, id.StateRootInHeader)
+			if err != nil {
+				return dbPath, blocks, logs, fmt.Errorf("cannot decode archive file %s: %w", hdr.Name, err)
+			}
+
+			if name != strconv.FormatUint(uint64(b.Index), 10)+blockFileExt {
+				return dbPath, blocks, logs, fmt.Errorf("archive file %s contains block %d", hdr.Name, b.Index)
+			}
+
+			if b.Index == 0 && !b.Hash().Equals(id.Genesis) {
+				return dbPath, blocks, logs, fmt.Errorf("%w %d: genesis hash mismatch", ErrInvalidBlock, b.Index)
+			}
+
+			// hashes are computed once for storing and indexing
+			_ = containers(b)
+
+			pendBlocks = append(pendBlocks, archiveBlock{block: b, data: encodeRecord(data, compress)})
+			indices = append(indices, b.Index)
+
+			if progress != nil && b.Index >= meta.From && b.Index < meta.To {
+				progress(int(b.Index-meta.From)+1, int(meta.To-meta.From))
+			}
+		case dirLogs:
+			h, err := util.Uint256DecodeStringLE(strings.TrimSuffix(name, logFileExt))
+			if err != nil {
+				return dbPath, blocks, logs, fmt.Errorf("unexpected archive file %s", hdr.Name)
+			}
+
+			appLog := new(result.ApplicationLog)
+			if err = appLog.UnmarshalJSON(data); err != nil {
+				return dbPath, blocks, logs, fmt.Errorf("cannot decode archive file %s: %w", hdr.Name, err)
+			}
+
+			if !appLog.Container.Equals(h) {
+				return dbPath, blocks, logs, fmt.Errorf("archive file %s contains application log of %s",
+					hdr.Name, appLog.Container.StringLE())
+			}
+
+			data, err = encodeLog(appLog, compress)
+			if err != nil {
+				return dbPath, blocks, logs, fmt.Errorf("cannot encode application log of %s: %w", h.StringLE(), err)
+			}
+
+			pendLogs = append(pendLogs, archiveLog{hash: h, data: data})
+		default:
+			return dbPath, blocks, logs, fmt.Errorf("unexpected archive file %s", hdr.Name)
+		}
+
+		if size += len(data); size >= bulkBufferSize {
+			if err = flush(); err != nil {
+				return dbPath, blocks, logs, fmt.Errorf("cannot write archive to database [%s]: %w", dbPath, err)
+			}
+		}
+	}
+
+	if err = flush(); err != nil {
+		return dbPath, blocks, logs, fmt.Errorf("cannot write archive to database [%s]: %w", dbPath, err)
+	}
+
+	// blocks are indexed once all application logs of the archive are
+	// stored, logs of some blocks may be cached before
+	for len(indices) != 0 {
+		n := migrationBatchSize
+		if n > len(indices) {
+			n = len(indices)
+		}
+
+		err = db.Update(func(tx *bbolt.Tx) error {
+			bkt := tx.Bucket(blocksBucket)
+			for _, index := range indices[:n] {
+				if err := indexRecord(tx, nil, bkt.Get(blockKey(index))); err != nil {
+					return fmt.Errorf("block %d: %w", index, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return dbPath, blocks, logs, fmt.Errorf("cannot index notifications of database [%s]: %w", dbPath, err)
+		}
+
+		indices = indices[n:]
+	}
+
+	return dbPath, blocks, logs, nil
+}
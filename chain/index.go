@@ -0,0 +1,534 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// Notification index maps notification name and contract to the positions
+// of notification events in blocks. Keys of the index are
+//
+//	len(name) | name | contract | block index | position
+//
+// where block index and position are big endian, so events of the
+// (name, contract) pair are ordered by blocks. Position is an index of the
+// event among all notifications of the block, as AllNotifications returns
+// them. Values are
+//
+//	container hash | event
+//
+// where container is a transaction or a block for OnPersist and PostPersist
+// triggers, and event is an index of the event in the application log.
+//
+// Blocks are indexed when every application log of the block is cached.
+// Indexed blocks are marked in a separate bucket with big endian keys.
+var (
+	notificationsBucket = []byte("notifications")
+	indexedBucket       = []byte("indexed")
+)
+
+// rangeCheckInterval is the amount of index records processed between
+// context checks.
+const rangeCheckInterval = 10000
+
+// IndexedNotification is a position of notification event found in the
+// notification index.
+type IndexedNotification struct {
+	Block uint32
+	// Position is an index of the event among all notifications of the
+	// block.
+	Position uint32
+	// Container is a hash of the transaction or the block which produced
+	// the event.
+	Container util.Uint256
+	// Event is an index of the event in the application log of the
+	// container.
+	Event uint32
+}
+
+// IndexedRanges returns ranges of blocks in [from, to) interval which are
+// present in the notification index.
+func (d *Chain) IndexedRanges(ctx context.Context, from, to uint32) ([]Range, error) {
+	var indices []uint32
+
+	if d.db == nil {
+		return nil, nil
+	}
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(indexedBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		c := bkt.Cursor()
+		for k, _ := c.Seek(indexKey(from)); k != nil; k, _ = c.Next() {
+			index := binary.BigEndian.Uint32(k)
+			if index >= to {
+				break
+			}
+			indices = append(indices, index)
+
+			if len(indices)%rangeCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read notification index: %w", err)
+	}
+
+	return toRanges(indices), nil
+}
+
+// FindNotifications returns positions of notification events with the
+// specified name in blocks of the ranges, see IndexedRanges. Ranges must be
+// ordered and must not overlap. If contract is nil, events of every
+// contract are returned. Positions are ordered by blocks and by events in
+// the block.
+func (d *Chain) FindNotifications(ctx context.Context, name string, contract *util.Uint160, ranges []Range) ([]IndexedNotification, error) {
+	var (
+		res     []IndexedNotification
+		checked int
+	)
+
+	if d.db == nil || len(ranges) == 0 {
+		return nil, nil
+	}
+
+	// scan checks records with the prefix starting from the key until the
+	// last block
+	scan := func(c *bbolt.Cursor, prefix, start []byte, last uint32) error {
+		for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			n, err := parseIndexRecord(k, v)
+			if err != nil {
+				return err
+			}
+
+			if n.Block > last {
+				return nil
+			}
+
+			if inRanges(ranges, n.Block) {
+				res = append(res, n)
+			}
+
+			if checked++; checked%rangeCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(notificationsBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		prefix := notificationPrefix(name, contract)
+		c := bkt.Cursor()
+
+		if contract == nil {
+			// records of the name are ordered by contracts, so every
+			// record is checked once
+			return scan(c, prefix, prefix, math.MaxUint32)
+		}
+
+		// records of the contract are ordered by blocks
+		for _, r := range ranges {
+			start := append(append([]byte{}, prefix...), indexKey(r.First)...)
+			if err := scan(c, prefix, start, r.Last); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read notification index: %w", err)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Block != res[j].Block {
+			return res[i].Block < res[j].Block
+		}
+		return res[i].Position < res[j].Position
+	})
+
+	return res, nil
+}
+
+// inRanges checks if the block belongs to one of ordered ranges.
+func inRanges(ranges []Range, i uint32) bool {
+	k := sort.Search(len(ranges), func(k int) bool {
+		return ranges[k].Last >= i
+	})
+
+	return k < len(ranges) && ranges[k].First <= i
+}
+
+// IndexedNames returns names of notifications present in the notification
+// index.
+func (d *Chain) IndexedNames(ctx context.Context) ([]string, error) {
+	var res []string
+
+	if d.db == nil {
+		return nil, nil
+	}
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(notificationsBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		c := bkt.Cursor()
+		for k, _ := c.First(); k != nil; {
+			if len(k) < 1+int(k[0]) {
+				return fmt.Errorf("invalid index record key %x", k)
+			}
+
+			name := k[1 : 1+int(k[0])]
+			res = append(res, string(name))
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// skip every record of the name, they share the prefix and
+			// have the same length
+			next := append([]byte{}, k[:1+len(name)]...)
+			next = append(next, bytes.Repeat([]byte{0xff}, len(k)-len(next)+1)...)
+			k, _ = c.Seek(next)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read notification index: %w", err)
+	}
+
+	return res, nil
+}
+
+// NotificationEvents returns notification events found in the notification
+// index. Application log of every container is read once.
+func (d *Chain) NotificationEvents(ctx context.Context, ns []IndexedNotification) ([]Event, error) {
+	var (
+		res    = make([]Event, 0, len(ns))
+		events = make(map[util.Uint256][]Event)
+	)
+
+	for _, n := range ns {
+		evs, ok := events[n.Container]
+		if !ok {
+			appLog, err := d.ApplicationLog(ctx, n.Container)
+			if err != nil {
+				return nil, err
+			}
+			evs = executionEvents(n.Container, appLog)
+			events[n.Container] = evs
+		}
+
+		if int(n.Event) >= len(evs) {
+			return nil, fmt.Errorf("notification index points to missing event %d of %s",
+				n.Event, n.Container.StringLE())
+		}
+		res = append(res, evs[n.Event])
+	}
+
+	return res, nil
+}
+
+// RebuildIndex drops notification index of the cache and builds it again
+// from cached blocks and application logs.
+func RebuildIndex(dbPath string, progress MigrationProgress) error {
+	db, err := openDB(dbPath, false, maintenanceLockTimeout, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if version > SchemaVersion {
+		return fmt.Errorf("cache schema version %d is newer than supported %d, update monza", version, SchemaVersion)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{notificationsBucket, indexedBucket} {
+			if tx.Bucket(name) == nil {
+				continue
+			}
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		if meta := tx.Bucket(metaBucket); meta != nil {
+			return meta.Delete(migrationKey)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot drop notification index of database [%s]: %w", dbPath, err)
+	}
+
+	index := migration{
+		version: version,
+		name:    "notification index",
+		bucket:  blocksBucket,
+		update:  indexRecord,
+	}
+
+	err = index.run(db, progress)
+	if err != nil {
+		return fmt.Errorf("cannot build notification index of database [%s]: %w", dbPath, err)
+	}
+
+	return nil
+}
+
+// index adds notifications of the block to the notification index unless
+// block is already indexed. Logs contain application log of the block and
+// its transactions in order.
+func (d *Chain) index(b *block.Block, logs []*result.ApplicationLog) error {
+	var indexed bool
+
+	if d.bulk != nil {
+		d.bulk.putIndex(b, logs)
+		return nil
+	}
+
+	if d.db == nil || d.db.IsReadOnly() {
+		return nil
+	}
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		indexed = isIndexed(tx, b.Index)
+		return nil
+	})
+	if err != nil || indexed {
+		return err
+	}
+
+	err = d.db.Batch(func(tx *bbolt.Tx) error {
+		return indexBlock(tx, b, logs)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot index notifications of block %d: %w", b.Index, err)
+	}
+
+	return nil
+}
+
+// indexRecord indexes block record of the blocks bucket if every
+// application log of the block is cached.
+func indexRecord(tx *bbolt.Tx, _, v []byte) error {
+	b, err := decodeAnyBlock(v)
+	if err != nil {
+		return err
+	}
+
+	logs, err := cachedLogs(tx, b)
+	if err != nil || logs == nil {
+		return err
+	}
+
+	return indexBlock(tx, b, logs)
+}
+
+// cachedLogs returns application logs of the block and its transactions or
+// nil if any of them is not cached.
+func cachedLogs(tx *bbolt.Tx, b *block.Block) ([]*result.ApplicationLog, error) {
+	bkt := tx.Bucket(logsBucket)
+	if !logsCached(bkt, b) {
+		return nil, nil
+	}
+
+	res := make([]*result.ApplicationLog, 0, len(b.Transactions)+1)
+	for _, h := range containers(b) {
+		appLog, err := decodeLog(bkt.Get(h.BytesLE()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode application log of %s: %w", h.StringLE(), err)
+		}
+		res = append(res, appLog)
+	}
+
+	return res, nil
+}
+
+func indexBlock(tx *bbolt.Tx, b *block.Block, logs []*result.ApplicationLog) error {
+	if isIndexed(tx, b.Index) {
+		return nil
+	}
+
+	bkt, err := tx.CreateBucketIfNotExists(notificationsBucket)
+	if err != nil {
+		return err
+	}
+
+	var position uint32
+	for i, h := range containers(b) {
+		for event, ev := range logEvents(logs[i]) {
+			err = bkt.Put(
+				indexRecordKey(ev.Name, ev.ScriptHash, b.Index, position),
+				indexRecordValue(h, uint32(event)),
+			)
+			if err != nil {
+				return err
+			}
+			position++
+		}
+	}
+
+	indexed, err := tx.CreateBucketIfNotExists(indexedBucket)
+	if err != nil {
+		return err
+	}
+
+	return indexed.Put(indexKey(b.Index), []byte{})
+}
+
+// unindexBlock removes notifications of the block from the notification
+// index. It must be called before application logs of the block are
+// removed.
+func unindexBlock(tx *bbolt.Tx, b *block.Block) error {
+	indexed := tx.Bucket(indexedBucket)
+	if indexed == nil || indexed.Get(indexKey(b.Index)) == nil {
+		return nil
+	}
+
+	logs, err := cachedLogs(tx, b)
+	if err != nil {
+		return err
+	}
+
+	if bkt := tx.Bucket(notificationsBucket); bkt != nil && logs != nil {
+		var position uint32
+		for i := range containers(b) {
+			for _, ev := range logEvents(logs[i]) {
+				err = bkt.Delete(indexRecordKey(ev.Name, ev.ScriptHash, b.Index, position))
+				if err != nil {
+					return err
+				}
+				position++
+			}
+		}
+	}
+
+	return indexed.Delete(indexKey(b.Index))
+}
+
+func isIndexed(tx *bbolt.Tx, index uint32) bool {
+	bkt := tx.Bucket(indexedBucket)
+	return bkt != nil && bkt.Get(indexKey(index)) != nil
+}
+
+// containers returns hashes of the block and its transactions in the order
+// of application logs processing.
+func containers(b *block.Block) []util.Uint256 {
+	res := make([]util.Uint256, 0, len(b.Transactions)+1)
+	res = append(res, b.Hash())
+	for _, tx := range b.Transactions {
+		res = append(res, tx.Hash())
+	}
+
+	return res
+}
+
+func logEvents(appLog *result.ApplicationLog) []state.NotificationEvent {
+	var res []state.NotificationEvent
+	for _, execution := range appLog.Executions {
+		res = append(res, execution.Events...)
+	}
+
+	return res
+}
+
+// executionEvents returns notification events of the application log of the
+// container in the order of logEvents.
+func executionEvents(container util.Uint256, appLog *result.ApplicationLog) []Event {
+	var res []Event
+	for _, execution := range appLog.Executions {
+		for _, ev := range execution.Events {
+			res = append(res, Event{
+				NotificationEvent: ev,
+				Container:         container,
+				Trigger:           execution.Trigger,
+			})
+		}
+	}
+
+	return res
+}
+
+func indexKey(index uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, index)
+
+	return key
+}
+
+func notificationPrefix(name string, contract *util.Uint160) []byte {
+	prefix := make([]byte, 0, 1+len(name)+util.Uint160Size)
+	prefix = append(prefix, byte(len(name)))
+	prefix = append(prefix, name...)
+	if contract != nil {
+		prefix = append(prefix, contract.BytesBE()...)
+	}
+
+	return prefix
+}
+
+func indexRecordKey(name string, contract util.Uint160, index, position uint32) []byte {
+	key := notificationPrefix(name, &contract)
+	key = append(key, indexKey(index)...)
+
+	return append(key, indexKey(position)...)
+}
+
+func indexRecordValue(container util.Uint256, event uint32) []byte {
+	return append(container.BytesLE(), indexKey(event)...)
+}
+
+func parseIndexRecord(k, v []byte) (IndexedNotification, error) {
+	if len(k) < 1 || len(k) != 1+int(k[0])+util.Uint160Size+8 || len(v) != util.Uint256Size+4 {
+		return IndexedNotification{}, fmt.Errorf("corrupted notification index record %x", k)
+	}
+
+	k = k[len(k)-8:]
+	container, err := util.Uint256DecodeBytesLE(v[:util.Uint256Size])
+	if err != nil {
+		return IndexedNotification{}, err
+	}
+
+	return IndexedNotification{
+		Block:     binary.BigEndian.Uint32(k[:4]),
+		Position:  binary.BigEndian.Uint32(k[4:]),
+		Container: container,
+		Event:     binary.BigEndian.Uint32(v[util.Uint256Size:]),
+	}, nil
+}
@@ -0,0 +1,63 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// ErrCacheLocked is returned when the cache file is not released by another
+// process in time. Any amount of processes may read the cache, but the
+// process which writes to the cache locks it exclusively.
+var ErrCacheLocked = errors.New("cache is locked by another process")
+
+const (
+	// lockCheckTimeout is the time of the first attempt to lock the cache
+	// file. Waiting for the lock is reported after it.
+	lockCheckTimeout = 100 * time.Millisecond
+
+	// maintenanceLockTimeout is the time of waiting for the lock by cache
+	// maintenance functions.
+	maintenanceLockTimeout = time.Second
+)
+
+// openDB opens the cache file waiting at most timeout for the lock held by
+// other processes, zero timeout means waiting until the lock is released.
+// Locked is called when the lock is not acquired at the first attempt.
+func openDB(dbPath string, readOnly bool, timeout time.Duration, locked func(dbPath string)) (*bbolt.DB, error) {
+	opts := &bbolt.Options{
+		ReadOnly: readOnly,
+		Timeout:  lockCheckTimeout,
+	}
+
+	if timeout > 0 && timeout <= lockCheckTimeout {
+		opts.Timeout = timeout
+	}
+
+	db, err := bbolt.Open(dbPath, 0600, opts)
+	if errors.Is(err, bbolt.ErrTimeout) && opts.Timeout != timeout {
+		if locked != nil {
+			locked(dbPath)
+		}
+
+		// bbolt waits for the lock infinitely with zero timeout
+		opts.Timeout = 0
+		if timeout > 0 {
+			opts.Timeout = timeout - lockCheckTimeout
+		}
+
+		db, err = bbolt.Open(dbPath, 0600, opts)
+	}
+
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("database [%s] init: %w", dbPath, ErrCacheLocked)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("database [%s] init: %w", dbPath, err)
+	}
+
+	return db, nil
+}
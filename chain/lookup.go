@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// Cached blocks are looked up by hashes of transactions and blocks. Keys of
+// the lookup buckets are hashes in little endian, values are block indices
+// in little endian like keys of blocks bucket.
+var (
+	transactionsBucket = []byte("transactions")
+	hashesBucket       = []byte("hashes")
+)
+
+// TransactionBlock returns index of the block containing the transaction.
+// Blocks in the cache are looked up first.
+func (d *Chain) TransactionBlock(ctx context.Context, txHash util.Uint256) (uint32, error) {
+	index, ok, err := d.lookup(transactionsBucket, txHash)
+	if err != nil || ok {
+		return index, err
+	}
+
+	if d.Offline() {
+		return 0, fmt.Errorf("%w: tx %s", ErrNotCached, txHash.StringLE())
+	}
+
+	err = d.call(ctx, func(cli *rpcclient.Client) (err error) {
+		index, err = cli.GetTransactionHeight(txHash)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("height of tx %s fetch: %w", txHash.StringLE(), err)
+	}
+
+	return index, nil
+}
+
+// lookup returns block index stored in the lookup bucket.
+func (d *Chain) lookup(bucket []byte, h util.Uint256) (index uint32, ok bool, err error) {
+	if d.db == nil {
+		return 0, false, nil
+	}
+
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(bucket)
+		if bkt == nil {
+			return nil
+		}
+
+		v := bkt.Get(h.BytesLE())
+		if v == nil {
+			return nil
+		}
+
+		if len(v) != 4 {
+			return fmt.Errorf("corrupted block index %x", v)
+		}
+
+		index, ok = binary.LittleEndian.Uint32(v), true
+		return nil
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot look up %s in cache: %w", h.StringLE(), err)
+	}
+
+	return index, ok, nil
+}
+
+// putHashes adds hashes of the block and its transactions to the lookup
+// buckets.
+func putHashes(tx *bbolt.Tx, b *block.Block) error {
+	hashes, err := tx.CreateBucketIfNotExists(hashesBucket)
+	if err != nil {
+		return err
+	}
+
+	txs, err := tx.CreateBucketIfNotExists(transactionsBucket)
+	if err != nil {
+		return err
+	}
+
+	index := make([]byte, 4)
+	binary.LittleEndian.PutUint32(index, b.Index)
+
+	if err = hashes.Put(b.Hash().BytesLE(), index); err != nil {
+		return err
+	}
+
+	for _, t := range b.Transactions {
+		if err = txs.Put(t.Hash().BytesLE(), index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteHashes removes hashes of the block and its transactions from the
+// lookup buckets.
+func deleteHashes(tx *bbolt.Tx, b *block.Block) error {
+	if bkt := tx.Bucket(hashesBucket); bkt != nil {
+		if err := bkt.Delete(b.Hash().BytesLE()); err != nil {
+			return err
+		}
+	}
+
+	if bkt := tx.Bucket(transactionsBucket); bkt != nil {
+		for _, t := range b.Transactions {
+			if err := bkt.Delete(t.Hash().BytesLE()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hashesRecord adds hashes of block record of the blocks bucket to the
+// lookup buckets.
+func hashesRecord(tx *bbolt.Tx, _, v []byte) error {
+	b, err := decodeAnyBlock(v)
+	if err != nil {
+		return err
+	}
+
+	return putHashes(tx, b)
+}
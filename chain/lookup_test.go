@@ -0,0 +1,128 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// blockServer returns RPC node which knows only the block, it records
+// requested hashes of getblock method.
+func blockServer(t *testing.T, b *block.Block, requested *[]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "getversion":
+			resp["result"] = map[string]interface{}{
+				"tcpport": 1, "nonce": 1, "useragent": "/test/",
+				"protocol": map[string]interface{}{"network": 42, "msperblock": 15000, "addressversion": 53},
+			}
+		case "getnativecontracts":
+			resp["result"] = []interface{}{}
+		case "getblock":
+			var s string
+			_ = json.Unmarshal(req.Params[0], &s)
+			*requested = append(*requested, s)
+
+			if s == b.Hash().StringLE() {
+				resp["result"] = result.Block{Block: *b, BlockMetadata: result.BlockMetadata{Confirmations: 1}}
+			} else {
+				resp["error"] = neorpc.ErrUnknownBlock
+			}
+		default:
+			resp["error"] = neorpc.NewError(neorpc.MethodNotFoundCode, "Method not found", req.Method)
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestBlockByHash(t *testing.T) {
+	b := block.New(false)
+	b.Index = 5
+	b.Timestamp = 1600000000000
+	b.MerkleRoot = b.ComputeMerkleRoot()
+
+	h := b.Hash()
+	ctx := context.Background()
+
+	var requested []string
+	srv := blockServer(t, b, &requested)
+
+	cli, err := rpcclient.New(ctx, srv.URL, rpcclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openCache(filepath.Join(t.TempDir(), "test.db"), false, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Chain{
+		db:        db,
+		store:     newBoltStorage(db, false),
+		endpoints: []*endpoint{{address: srv.URL, client: cli}},
+		workers:   1,
+		released:  make(chan struct{}),
+	}
+	defer d.Close()
+
+	// hash is requested in the order it is printed, as Block.Hash returns it
+	res, err := d.BlockByHash(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Hash().Equals(h) || len(requested) != 1 || requested[0] != h.StringLE() {
+		t.Fatalf("unexpected block %d, requested hashes %v", res.Index, requested)
+	}
+
+	// fetched block is found in the cache by the same hash
+	d.endpoints = nil
+
+	res, err = d.BlockByHash(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Hash().Equals(h) || len(requested) != 1 {
+		t.Fatalf("unexpected block %d, requested hashes %v", res.Index, requested)
+	}
+
+	_, err = d.BlockByHash(ctx, h.Reverse())
+	if !errors.Is(err, ErrNotCached) {
+		t.Fatalf("reversed hash is found: %v", err)
+	}
+
+	var zero util.Uint256
+	if _, err = d.BlockByHash(ctx, zero); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("unknown hash is found: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// memoryStorage keeps serialized blocks and application logs in memory, so
+// every caller gets its own copy of stored data.
+type memoryStorage struct {
+	mu        sync.RWMutex
+	stateRoot bool
+	blocks    map[uint32][]byte
+	logs      map[util.Uint256][]byte
+}
+
+// NewMemoryStorage returns storage which keeps blocks and application logs
+// in memory until it is closed. It is useful for tests and one-off runs.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{
+		blocks: make(map[uint32][]byte),
+		logs:   make(map[util.Uint256][]byte),
+	}
+}
+
+func (s *memoryStorage) Block(index uint32) (*block.Block, error) {
+	s.mu.RLock()
+	data, ok := s.blocks[index]
+	stateRoot := s.stateRoot
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	return decodeBlock(data, stateRoot)
+}
+
+func (s *memoryStorage) PutBlock(b *block.Block) error {
+	data, err := encodeBlock(b, false)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	// every block of the chain has the same header format
+	s.stateRoot = b.StateRootEnabled
+	s.blocks[b.Index] = data
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *memoryStorage) ApplicationLog(h util.Uint256) (*result.ApplicationLog, error) {
+	s.mu.RLock()
+	data, ok := s.logs[h]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	return decodeLog(data)
+}
+
+func (s *memoryStorage) PutApplicationLog(h util.Uint256, appLog *result.ApplicationLog) error {
+	data, err := encodeLog(appLog, false)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.logs[h] = data
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *memoryStorage) Close() error {
+	s.mu.Lock()
+	s.blocks = make(map[uint32][]byte)
+	s.logs = make(map[util.Uint256][]byte)
+	s.mu.Unlock()
+
+	return nil
+}
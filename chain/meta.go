@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// Identity describes the chain the cache belongs to. Different chains may
+// share the same network magic, e.g. private or reset test chains, so
+// genesis block hash is stored as well.
+type Identity struct {
+	Genesis              util.Uint256
+	Magic                uint32
+	StateRootInHeader    bool
+	MillisecondsPerBlock uint32
+}
+
+// ErrChainMismatch is returned when the cache belongs to another chain
+// than the remote node.
+var ErrChainMismatch = errors.New("cache belongs to another chain")
+
+var (
+	metaBucket = []byte("meta")
+
+	genesisKey    = []byte("genesis")
+	magicKey      = []byte("magic")
+	stateRootKey  = []byte("stateroot")
+	msPerBlockKey = []byte("msperblock")
+)
+
+func (i Identity) String() string {
+	return fmt.Sprintf("genesis:%s magic:%d stateroot:%t msperblock:%d",
+		i.Genesis.StringLE(), i.Magic, i.StateRootInHeader, i.MillisecondsPerBlock,
+	)
+}
+
+// checkIdentity compares chain identity stored in the cache with the
+// expected one. Identity is stored if the cache does not have it yet.
+func checkIdentity(db *bbolt.DB, expected Identity) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		stored, err := readIdentity(tx)
+		if err != nil {
+			return err
+		}
+
+		if stored != nil {
+			if *stored != expected {
+				return fmt.Errorf("%w: cache has %s, remote node has %s", ErrChainMismatch, stored, expected)
+			}
+			return nil
+		}
+
+		// caches created before identity was stored can be checked
+		// only if they contain genesis block
+		if bkt := tx.Bucket(blocksBucket); bkt != nil {
+			data := bkt.Get(make([]byte, 4))
+			if len(data) != 0 {
+				genesis, err := decodeBlock(data, expected.StateRootInHeader)
+				if err != nil || !genesis.Hash().Equals(expected.Genesis) {
+					return fmt.Errorf("%w: cached genesis block does not match genesis %s of remote node",
+						ErrChainMismatch, expected.Genesis.StringLE())
+				}
+			}
+		}
+
+		return writeIdentity(tx, expected)
+	})
+}
+
+// readIdentity returns chain identity stored in the cache or nil if there
+// is none.
+func readIdentity(tx *bbolt.Tx) (*Identity, error) {
+	bkt := tx.Bucket(metaBucket)
+	if bkt == nil {
+		return nil, nil
+	}
+
+	genesis := bkt.Get(genesisKey)
+	if genesis == nil {
+		return nil, nil
+	}
+
+	magic, stateRoot, msPerBlock := bkt.Get(magicKey), bkt.Get(stateRootKey), bkt.Get(msPerBlockKey)
+	if len(magic) != 4 || len(stateRoot) != 1 || len(msPerBlock) != 4 {
+		return nil, errors.New("corrupted chain identity")
+	}
+
+	h, err := util.Uint256DecodeBytesLE(genesis)
+	if err != nil {
+		return nil, fmt.Errorf("corrupted chain identity: %w", err)
+	}
+
+	return &Identity{
+		Genesis:              h,
+		Magic:                binary.LittleEndian.Uint32(magic),
+		StateRootInHeader:    stateRoot[0] == 1,
+		MillisecondsPerBlock: binary.LittleEndian.Uint32(msPerBlock),
+	}, nil
+}
+
+func writeIdentity(tx *bbolt.Tx, id Identity) error {
+	bkt, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	magic := make([]byte, 4)
+	binary.LittleEndian.PutUint32(magic, id.Magic)
+
+	msPerBlock := make([]byte, 4)
+	binary.LittleEndian.PutUint32(msPerBlock, id.MillisecondsPerBlock)
+
+	stateRoot := []byte{0}
+	if id.StateRootInHeader {
+		stateRoot[0] = 1
+	}
+
+	for k, v := range map[string][]byte{
+		string(genesisKey):    id.Genesis.BytesLE(),
+		string(magicKey):      magic,
+		string(stateRootKey):  stateRoot,
+		string(msPerBlockKey): msPerBlock,
+	} {
+		if err = bkt.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
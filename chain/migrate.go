@@ -0,0 +1,214 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// SchemaVersion is a version of the cache layout produced by this package.
+const SchemaVersion = 5
+
+// MigrationProgress is called while the cache is being migrated to the new
+// schema version.
+type MigrationProgress func(name string, done, total int)
+
+// migration upgrades cache layout to the specified schema version by
+// processing records of the bucket one by one. Records are processed in
+// batches, and the key of the last processed record is stored in the cache,
+// so interrupted migration continues where it stopped.
+type migration struct {
+	version uint32
+	name    string
+	bucket  []byte
+	update  func(tx *bbolt.Tx, k, v []byte) error
+}
+
+// migrations contains every schema upgrade in the order of versions.
+// Caches without schema version have layout of version 1.
+var migrations = []migration{
+	{
+		version: 2,
+		name:    "notification index",
+		bucket:  blocksBucket,
+		update:  indexRecord,
+	},
+	{
+		version: 3,
+		name:    "transaction lookup",
+		bucket:  blocksBucket,
+		update:  hashesRecord,
+	},
+	{
+		version: 4,
+		name:    "binary application logs",
+		bucket:  logsBucket,
+		update:  reencodeLogRecord,
+	},
+	{
+		version: 5,
+		name:    "block records",
+		bucket:  blocksBucket,
+		update:  reencodeBlockRecord,
+	},
+}
+
+const migrationBatchSize = 1000
+
+var (
+	versionKey   = []byte("version")
+	migrationKey = []byte("migration")
+)
+
+// migrate upgrades cache layout to the SchemaVersion.
+func migrate(db *bbolt.DB, progress MigrationProgress) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if version > SchemaVersion {
+		return fmt.Errorf("cache schema version %d is newer than supported %d, update monza", version, SchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		err = m.run(db, progress)
+		if err != nil {
+			return fmt.Errorf("migration to schema version %d (%s): %w", m.version, m.name, err)
+		}
+	}
+
+	if version == SchemaVersion {
+		// empty cache is considered to have the latest version, it is
+		// stored, so the cache is not migrated once blocks are added
+		var stored bool
+		_ = db.View(func(tx *bbolt.Tx) error {
+			bkt := tx.Bucket(metaBucket)
+			stored = bkt != nil && bkt.Get(versionKey) != nil
+			return nil
+		})
+		if stored {
+			return nil
+		}
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		return setSchemaVersion(tx, SchemaVersion)
+	})
+}
+
+func (m migration) run(db *bbolt.DB, progress MigrationProgress) error {
+	var total, done int
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		if bkt := tx.Bucket(m.bucket); bkt != nil {
+			total = bkt.Stats().KeyN
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for finished := false; !finished; {
+		err = db.Update(func(tx *bbolt.Tx) error {
+			meta, err := tx.CreateBucketIfNotExists(metaBucket)
+			if err != nil {
+				return err
+			}
+
+			var keys, values [][]byte
+
+			if bkt := tx.Bucket(m.bucket); bkt != nil {
+				c := bkt.Cursor()
+				k, v := c.First()
+				if last := meta.Get(migrationKey); last != nil {
+					k, v = c.Seek(last)
+					if bytes.Equal(k, last) {
+						k, v = c.Next()
+					}
+				}
+
+				// bbolt cursor is not stable while bucket is modified,
+				// so collect the batch before processing
+				for ; k != nil && len(keys) < migrationBatchSize; k, v = c.Next() {
+					keys = append(keys, append([]byte{}, k...))
+					values = append(values, append([]byte{}, v...))
+				}
+			}
+
+			for i := range keys {
+				if err = m.update(tx, keys[i], values[i]); err != nil {
+					return fmt.Errorf("record %x: %w", keys[i], err)
+				}
+			}
+			done += len(keys)
+
+			if len(keys) < migrationBatchSize {
+				finished = true
+				if err = meta.Delete(migrationKey); err != nil {
+					return err
+				}
+				return setSchemaVersion(tx, m.version)
+			}
+
+			return meta.Put(migrationKey, keys[len(keys)-1])
+		})
+		if err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(m.name, done, total)
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion returns schema version of the cache. Empty caches are
+// considered to have the latest version, caches without version have
+// version 1.
+func schemaVersion(db *bbolt.DB) (version uint32, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		version, err = readSchemaVersion(tx)
+		return err
+	})
+
+	return version, err
+}
+
+func readSchemaVersion(tx *bbolt.Tx) (uint32, error) {
+	if bkt := tx.Bucket(metaBucket); bkt != nil {
+		if v := bkt.Get(versionKey); v != nil {
+			if len(v) != 4 {
+				return 0, fmt.Errorf("corrupted schema version %x", v)
+			}
+			return binary.LittleEndian.Uint32(v), nil
+		}
+	}
+
+	if tx.Bucket(blocksBucket) == nil && tx.Bucket(logsBucket) == nil {
+		return SchemaVersion, nil
+	}
+
+	return 1, nil
+}
+
+func setSchemaVersion(tx *bbolt.Tx, version uint32) error {
+	bkt, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	v := make([]byte, 4)
+	binary.LittleEndian.PutUint32(v, version)
+
+	return bkt.Put(versionKey, v)
+}
@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/neorpc"
+)
+
+const (
+	// DefaultRetryDelay is the default delay before the first retry of the
+	// failed request.
+	DefaultRetryDelay = 500 * time.Millisecond
+
+	// DefaultMaxRetryDelay is the default limit of the delay between
+	// retries.
+	DefaultMaxRetryDelay = 30 * time.Second
+)
+
+// unknownPrefix starts messages of RPC errors about missing blocks,
+// transactions, headers and contracts, they share RPCErrorCode with other
+// errors of request processing.
+const unknownPrefix = "Unknown "
+
+// permanent returns true if the request failed with an error which does
+// not go away on retry: unknown block, transaction, header or contract and
+// invalid request. Other errors, e.g. transport errors, timeouts, internal
+// server errors and limits of the node, are considered transient.
+func permanent(err error) bool {
+	var rpcErr *neorpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	switch rpcErr.Code {
+	case neorpc.InvalidParamsCode, neorpc.MethodNotFoundCode, neorpc.InvalidRequestCode, neorpc.BadRequestCode:
+		return true
+	case neorpc.RPCErrorCode:
+		return strings.HasPrefix(rpcErr.Message, unknownPrefix)
+	default:
+		return false
+	}
+}
+
+// retryPolicy repeats failed operations with exponential backoff.
+type retryPolicy struct {
+	attempts        int
+	delay, maxDelay time.Duration
+}
+
+// do performs f until success, permanent error, the limit of attempts or
+// context cancellation.
+func (r retryPolicy) do(ctx context.Context, f func() error) error {
+	for retry := 0; ; retry++ {
+		err := f()
+		if err == nil || permanent(err) || retry >= r.attempts || ctx.Err() != nil {
+			return err
+		}
+
+		t := time.NewTimer(backoff(retry, r.delay, r.maxDelay))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns delay before the retry with the specified number. Delay
+// grows exponentially and is randomized in [delay/2, delay) interval, so
+// parallel workers do not retry at the same time.
+func backoff(retry int, base, limit time.Duration) time.Duration {
+	d := base
+	for i := 0; i < retry && d < limit; i++ {
+		d *= 2
+	}
+
+	if d > limit {
+		d = limit
+	}
+
+	if d < 2 {
+		return d
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// limiter spreads requests evenly to keep the rate of requests per second.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rps float64) *limiter {
+	if rps <= 0 {
+		return nil
+	}
+
+	return &limiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// wait blocks until the next request is allowed or context is cancelled.
+// Nil limiter never blocks.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/neorpc"
+)
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		err       error
+		permanent bool
+	}{
+		{neorpc.ErrUnknownBlock, true},
+		{neorpc.ErrUnknownTransaction, true},
+		{neorpc.ErrUnknownHeader, true},
+		{neorpc.ErrUnknownScriptContainer, true},
+		{neorpc.NewRPCError("Unknown contract", ""), true},
+		{fmt.Errorf("node: %w", neorpc.ErrUnknownBlock), true},
+		{neorpc.ErrInvalidParams, true},
+		{neorpc.NewError(neorpc.MethodNotFoundCode, "Method not found", ""), true},
+		{neorpc.NewInternalServerError("database is closed"), false},
+		{neorpc.NewRPCError("Too many requests", ""), false},
+		{neorpc.NewError(-32001, "Request limit exceeded", ""), false},
+		{neorpc.ErrOutOfMemory, false},
+		{errors.New("connection refused"), false},
+		{context.DeadlineExceeded, false},
+	}
+
+	for _, tc := range tests {
+		if res := permanent(tc.err); res != tc.permanent {
+			t.Errorf("%v: expected %t, got %t", tc.err, tc.permanent, res)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	r := retryPolicy{attempts: 3, delay: 1, maxDelay: 1}
+
+	var calls int
+	err := r.do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return neorpc.NewRPCError("Too many requests", "")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("throttled request is not retried: %d calls, %v", calls, err)
+	}
+
+	calls = 0
+	err = r.do(context.Background(), func() error {
+		calls++
+		return neorpc.ErrUnknownBlock
+	})
+	if !errors.Is(err, neorpc.ErrUnknownBlock) || calls != 1 {
+		t.Fatalf("unknown block is retried: %d calls, %v", calls, err)
+	}
+
+	calls = 0
+	err = r.do(context.Background(), func() error {
+		calls++
+		return neorpc.NewInternalServerError("timeout")
+	})
+	if err == nil || calls != r.attempts+1 {
+		t.Fatalf("unexpected %d calls, %v", calls, err)
+	}
+}
@@ -0,0 +1,177 @@
+package chain
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/neorpc/result"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"go.etcd.io/bbolt"
+)
+
+// Storage keeps blocks and application logs fetched from RPC nodes.
+// Storage is used by multiple goroutines concurrently.
+type Storage interface {
+	// Block returns the block with the specified index or nil if the block
+	// is not stored.
+	Block(index uint32) (*block.Block, error)
+	// PutBlock stores the block.
+	PutBlock(b *block.Block) error
+	// ApplicationLog returns application log of the transaction or the
+	// block or nil if the log is not stored.
+	ApplicationLog(h util.Uint256) (*result.ApplicationLog, error)
+	// PutApplicationLog stores application log of the transaction or the
+	// block.
+	PutApplicationLog(h util.Uint256, appLog *result.ApplicationLog) error
+	// Close releases resources of the storage.
+	Close() error
+}
+
+// ErrReadOnly is returned by read-only storages on attempt to store data.
+// Chain keeps working with such storages, fetched data is not stored.
+var ErrReadOnly = errors.New("storage is read-only")
+
+// nopStorage stores nothing, so blocks and application logs are fetched
+// every time they are requested.
+type nopStorage struct{}
+
+func (nopStorage) Block(uint32) (*block.Block, error) { return nil, nil }
+
+func (nopStorage) PutBlock(*block.Block) error { return nil }
+
+func (nopStorage) ApplicationLog(util.Uint256) (*result.ApplicationLog, error) { return nil, nil }
+
+func (nopStorage) PutApplicationLog(util.Uint256, *result.ApplicationLog) error { return nil }
+
+func (nopStorage) Close() error { return nil }
+
+// boltStorage is a storage of the cache file. Besides blocks and application
+// logs it keeps notification index and lookup buckets, which are used by
+// the chain directly.
+type boltStorage struct {
+	db        *bbolt.DB
+	stateRoot bool
+	compress  bool
+}
+
+func newBoltStorage(db *bbolt.DB, stateRoot bool) *boltStorage {
+	s := &boltStorage{
+		db:        db,
+		stateRoot: stateRoot,
+	}
+
+	_ = db.View(func(tx *bbolt.Tx) error {
+		s.compress = compressed(tx)
+		return nil
+	})
+
+	return s
+}
+
+func (s *boltStorage) Block(index uint32) (res *block.Block, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, index)
+
+		bkt := tx.Bucket(blocksBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		data := bkt.Get(key)
+		if len(data) == 0 {
+			return nil
+		}
+
+		res, err = decodeBlock(data, s.stateRoot)
+		return err
+	})
+
+	return res, err
+}
+
+func (s *boltStorage) PutBlock(b *block.Block) error {
+	if s.db.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	data, err := encodeBlock(b, s.compress)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Batch(func(tx *bbolt.Tx) error {
+		return putBlock(tx, b, data)
+	})
+}
+
+func (s *boltStorage) ApplicationLog(h util.Uint256) (res *result.ApplicationLog, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(logsBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		data := bkt.Get(h.BytesLE())
+		if len(data) == 0 {
+			return nil
+		}
+
+		res, err = decodeLog(data)
+		return err
+	})
+
+	return res, err
+}
+
+func (s *boltStorage) PutApplicationLog(h util.Uint256, appLog *result.ApplicationLog) error {
+	if s.db.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	data, err := encodeLog(appLog, s.compress)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Batch(func(tx *bbolt.Tx) error {
+		return putLog(tx, h, data)
+	})
+}
+
+func (s *boltStorage) Close() error {
+	return s.db.Close()
+}
+
+// putBlock stores encoded block record and adds hashes of the block to the
+// lookup buckets. Cached header of the block is not needed anymore.
+func putBlock(tx *bbolt.Tx, b *block.Block, data []byte) error {
+	key := make([]byte, 4)
+	binary.LittleEndian.PutUint32(key, b.Index)
+
+	bkt, err := tx.CreateBucketIfNotExists(blocksBucket)
+	if err != nil {
+		return err
+	}
+
+	if err = bkt.Put(key, data); err != nil {
+		return err
+	}
+
+	if err = deleteHeader(tx, b.Index); err != nil {
+		return err
+	}
+
+	return putHashes(tx, b)
+}
+
+// putLog stores encoded application log record.
+func putLog(tx *bbolt.Tx, h util.Uint256, data []byte) error {
+	bkt, err := tx.CreateBucketIfNotExists(logsBucket)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Put(h.BytesLE(), data)
+}
@@ -0,0 +1,546 @@
+package chain
+
+import (
+	"context"
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm"
+	"github.com/nspcc-dev/neo-go/pkg/vm/opcode"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	// ErrInvalidBlock is returned when block does not pass verification.
+	ErrInvalidBlock = errors.New("invalid block")
+
+	// ErrUnanchored is returned when the block can not be verified, because
+	// headers between it and genesis or the closest verified block are
+	// neither cached nor available.
+	ErrUnanchored = errors.New("block is not anchored to verified blocks")
+)
+
+// verifiedBucket contains hashes and next consensus of verified blocks, so
+// verification continues from them instead of genesis. Keys are big endian
+// block indices unlike keys of blocks bucket, so the closest verified block
+// is found with a cursor seek.
+var verifiedBucket = []byte("verified")
+
+// anchorBatchSize is the amount of verified headers stored in the cache at
+// once while the chain is verified from the closest verified block.
+const anchorBatchSize = 1000
+
+// maxMemoryAnchors limits the amount of verified blocks kept in memory when
+// they can not be stored in the cache.
+const maxMemoryAnchors = 1024
+
+// anchor is a verified block. Blocks are verified against the previous
+// anchor, so every verified block is linked to genesis.
+type anchor struct {
+	index         uint32
+	hash          util.Uint256
+	nextConsensus util.Uint160
+}
+
+func newAnchor(h *block.Header) *anchor {
+	return &anchor{
+		index:         h.Index,
+		hash:          h.Hash(),
+		nextConsensus: h.NextConsensus,
+	}
+}
+
+// Verify checks cached blocks in [from, to) interval the same way as blocks
+// fetched in verification mode. Invalid blocks are removed from the cache.
+// Report is called for every cached block with nil error for valid blocks.
+func (d *Chain) Verify(ctx context.Context, from, to uint32, report func(index uint32, err error)) error {
+	var prev *anchor
+
+	for i := from; i < to; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		b, err := d.block(i)
+		if err != nil {
+			return err
+		}
+
+		if b == nil {
+			prev = nil
+			continue
+		}
+
+		err = d.verify(ctx, b, prev)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidBlock) {
+				return err
+			}
+
+			if err := d.removeBlock(b); err != nil {
+				return err
+			}
+
+			// next block should be checked against the closest verified
+			// block, not against invalid one
+			prev = nil
+		} else {
+			prev = newAnchor(&b.Header)
+		}
+
+		report(i, err)
+	}
+
+	return nil
+}
+
+// verify checks block against the previous verified block. If previous
+// block is nil, it is verified from the closest verified block, see
+// previous.
+func (d *Chain) verify(ctx context.Context, b *block.Block, prev *anchor) error {
+	if b.Index == 0 {
+		if !b.Hash().Equals(d.genesis) {
+			return fmt.Errorf("%w %d: genesis hash mismatch", ErrInvalidBlock, b.Index)
+		}
+		if err := verifyContents(b); err != nil {
+			return err
+		}
+		return d.addAnchors(newAnchor(&b.Header))
+	}
+
+	if prev == nil {
+		var err error
+		prev, err = d.previous(ctx, b.Index)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := verifyBlock(b, prev, d.magic)
+	if err != nil {
+		return err
+	}
+
+	return d.addAnchors(newAnchor(&b.Header))
+}
+
+// verifyHeader checks header the same way as verify checks blocks, except
+// for block contents.
+func (d *Chain) verifyHeader(ctx context.Context, h *block.Header) error {
+	if h.Index == 0 {
+		if !h.Hash().Equals(d.genesis) {
+			return fmt.Errorf("%w %d: genesis hash mismatch", ErrInvalidBlock, h.Index)
+		}
+		return d.addAnchors(newAnchor(h))
+	}
+
+	prev, err := d.previous(ctx, h.Index)
+	if err != nil {
+		return err
+	}
+
+	err = verifyHeader(h, prev, d.magic)
+	if err != nil {
+		return err
+	}
+
+	return d.addAnchors(newAnchor(h))
+}
+
+// previous returns the verified block preceding the block with the index.
+// Neither cached nor fetched headers are trusted: headers between the
+// closest verified block or genesis and the block are verified one by one.
+// Missing headers are fetched from the node and cached if they are valid.
+func (d *Chain) previous(ctx context.Context, index uint32) (*anchor, error) {
+	// parallel fetches verify the same headers otherwise
+	d.verifyMu.Lock()
+	defer d.verifyMu.Unlock()
+
+	prev, err := d.closestAnchor(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		i        uint32
+		verified []*anchor
+	)
+	if prev != nil {
+		i = prev.index + 1
+	}
+
+	for ; i < index; i++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		var (
+			h      *block.Header
+			cached bool
+		)
+		h, cached, err = d.anchorHeader(ctx, i, index)
+		if err != nil {
+			break
+		}
+
+		if i == 0 {
+			if !h.Hash().Equals(d.genesis) {
+				err = fmt.Errorf("%w %d: genesis hash mismatch", ErrInvalidBlock, h.Index)
+				break
+			}
+		} else if err = verifyHeader(h, prev, d.magic); err != nil {
+			break
+		}
+
+		// fetched headers are cached only when they are valid
+		if !cached {
+			if err = d.addHeader(h); err != nil {
+				break
+			}
+		}
+
+		prev = newAnchor(h)
+		verified = append(verified, prev)
+
+		if len(verified) == anchorBatchSize {
+			if err = d.addAnchors(verified...); err != nil {
+				return nil, err
+			}
+			verified = verified[:0]
+		}
+	}
+
+	// keep the progress of long verification even if it is interrupted
+	if addErr := d.addAnchors(verified...); err == nil {
+		err = addErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return prev, nil
+}
+
+// anchorHeader returns cached or fetched header of the block i to verify
+// block with the index.
+func (d *Chain) anchorHeader(ctx context.Context, i, index uint32) (h *block.Header, cached bool, err error) {
+	h, err = d.header(i)
+	if err != nil || h != nil {
+		return h, true, err
+	}
+
+	if d.Offline() {
+		return nil, false, fmt.Errorf("%w %d: header %d is not cached", ErrUnanchored, index, i)
+	}
+
+	h, err = d.fetchHeader(ctx, i)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if h.Index != i {
+		return nil, false, fmt.Errorf("%w %d: node returned header %d", ErrInvalidBlock, i, h.Index)
+	}
+
+	return h, false, nil
+}
+
+// closestAnchor returns verified block with the highest index lower than
+// the index or nil if there is no such block.
+func (d *Chain) closestAnchor(index uint32) (*anchor, error) {
+	var res *anchor
+
+	d.anchorMu.Lock()
+	for _, a := range d.anchors {
+		if a.index < index && (res == nil || a.index > res.index) {
+			res = a
+		}
+	}
+	d.anchorMu.Unlock()
+
+	if d.db == nil {
+		return res, nil
+	}
+
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, index)
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(verifiedBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		c := bkt.Cursor()
+		k, v := c.Seek(key)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		if k == nil {
+			return nil
+		}
+
+		a, err := decodeAnchor(k, v)
+		if err != nil {
+			return err
+		}
+
+		if res == nil || a.index > res.index {
+			res = a
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read verified blocks from cache: %w", err)
+	}
+
+	return res, nil
+}
+
+// addAnchors stores verified blocks in the cache. If the cache is not
+// writable, latest verified blocks are kept in memory.
+func (d *Chain) addAnchors(anchors ...*anchor) error {
+	if len(anchors) == 0 {
+		return nil
+	}
+
+	if d.db == nil || d.db.IsReadOnly() {
+		d.anchorMu.Lock()
+		defer d.anchorMu.Unlock()
+
+		if d.anchors == nil {
+			d.anchors = make(map[uint32]*anchor)
+		}
+
+		for _, a := range anchors {
+			d.anchors[a.index] = a
+		}
+
+		// blocks are fetched mostly in ascending order, so the lowest
+		// verified blocks are not needed anymore
+		for len(d.anchors) > maxMemoryAnchors {
+			lowest := uint32(math.MaxUint32)
+			for i := range d.anchors {
+				if i < lowest {
+					lowest = i
+				}
+			}
+			delete(d.anchors, lowest)
+		}
+
+		return nil
+	}
+
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists(verifiedBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range anchors {
+			k, v := encodeAnchor(a)
+			if err = bkt.Put(k, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot add verified blocks to cache: %w", err)
+	}
+
+	return nil
+}
+
+func encodeAnchor(a *anchor) (k, v []byte) {
+	k = make([]byte, 4)
+	binary.BigEndian.PutUint32(k, a.index)
+
+	v = make([]byte, 0, util.Uint256Size+util.Uint160Size)
+	v = append(v, a.hash.BytesLE()...)
+	v = append(v, a.nextConsensus.BytesBE()...)
+
+	return k, v
+}
+
+func decodeAnchor(k, v []byte) (*anchor, error) {
+	if len(k) != 4 || len(v) != util.Uint256Size+util.Uint160Size {
+		return nil, fmt.Errorf("corrupted verified block record %x", k)
+	}
+
+	a := &anchor{index: binary.BigEndian.Uint32(k)}
+	a.hash, _ = util.Uint256DecodeBytesLE(v[:util.Uint256Size])
+	a.nextConsensus, _ = util.Uint160DecodeBytesBE(v[util.Uint256Size:])
+
+	return a, nil
+}
+
+// deleteAnchor removes the block from verified blocks.
+func deleteAnchor(tx *bbolt.Tx, index uint32) error {
+	bkt := tx.Bucket(verifiedBucket)
+	if bkt == nil {
+		return nil
+	}
+
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, index)
+
+	return bkt.Delete(key)
+}
+
+// removeBlock deletes block, its cached header and application logs of the
+// block and its transactions from the cache.
+func (d *Chain) removeBlock(b *block.Block) error {
+	if d.db == nil {
+		return fmt.Errorf("cannot remove block %d: storage does not support removal", b.Index)
+	}
+
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		if err := unindexBlock(tx, b); err != nil {
+			return err
+		}
+
+		if err := deleteHashes(tx, b); err != nil {
+			return err
+		}
+
+		if err := deleteHeader(tx, b.Index); err != nil {
+			return err
+		}
+
+		if err := deleteAnchor(tx, b.Index); err != nil {
+			return err
+		}
+
+		if bkt := tx.Bucket(logsBucket); bkt != nil {
+			if _, err := deleteLogs(bkt, b); err != nil {
+				return err
+			}
+		}
+
+		bkt := tx.Bucket(blocksBucket)
+		if bkt == nil {
+			return nil
+		}
+
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, b.Index)
+
+		return bkt.Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot remove block %d from cache: %w", b.Index, err)
+	}
+
+	return nil
+}
+
+// verifyBlock checks that block contents match its header, block is linked
+// to the previous block and signed by consensus nodes defined in the
+// previous block.
+func verifyBlock(b *block.Block, prev *anchor, magic uint32) error {
+	err := verifyContents(b)
+	if err != nil {
+		return err
+	}
+
+	return verifyHeader(&b.Header, prev, magic)
+}
+
+// verifyHeader checks that header is linked to the previous block and
+// signed by consensus nodes defined in the previous block.
+func verifyHeader(h *block.Header, prev *anchor, magic uint32) error {
+	err := verifyLink(h, prev)
+	if err != nil {
+		return err
+	}
+
+	return verifyWitness(h, prev.nextConsensus, magic)
+}
+
+// verifyLink checks that header follows the previous block.
+func verifyLink(h *block.Header, prev *anchor) error {
+	if prev.index+1 != h.Index {
+		return fmt.Errorf("%w %d: unexpected previous block %d", ErrInvalidBlock, h.Index, prev.index)
+	}
+
+	if !prev.hash.Equals(h.PrevHash) {
+		return fmt.Errorf("%w %d: previous block hash mismatch", ErrInvalidBlock, h.Index)
+	}
+
+	return nil
+}
+
+func verifyContents(b *block.Block) error {
+	if !b.MerkleRoot.Equals(b.ComputeMerkleRoot()) {
+		return fmt.Errorf("%w %d: merkle root mismatch", ErrInvalidBlock, b.Index)
+	}
+
+	return nil
+}
+
+// verifyWitness checks block signatures against multisig verification
+// script with the hash of next consensus defined in the previous block.
+func verifyWitness(b *block.Header, nextConsensus util.Uint160, magic uint32) error {
+	if !b.Script.ScriptHash().Equals(nextConsensus) {
+		return fmt.Errorf("%w %d: witness does not match next consensus of previous block", ErrInvalidBlock, b.Index)
+	}
+
+	m, pubs, ok := vm.ParseMultiSigContract(b.Script.VerificationScript)
+	if !ok {
+		pub, ok := vm.ParseSignatureContract(b.Script.VerificationScript)
+		if !ok {
+			return fmt.Errorf("%w %d: unsupported verification script", ErrInvalidBlock, b.Index)
+		}
+		m, pubs = 1, [][]byte{pub}
+	}
+
+	sigs, err := parseSignatures(b.Script.InvocationScript)
+	if err != nil || len(sigs) != m {
+		return fmt.Errorf("%w %d: invalid invocation script", ErrInvalidBlock, b.Index)
+	}
+
+	// signatures are ordered the same way as public keys
+	var s int
+	for k := 0; k < len(pubs) && s < len(sigs); k++ {
+		pub, err := keys.NewPublicKeyFromBytes(pubs[k], elliptic.P256())
+		if err != nil {
+			return fmt.Errorf("%w %d: invalid public key: %v", ErrInvalidBlock, b.Index, err)
+		}
+
+		if pub.VerifyHashable(sigs[s], magic, b) {
+			s++
+		}
+	}
+
+	if s != len(sigs) {
+		return fmt.Errorf("%w %d: invalid signature", ErrInvalidBlock, b.Index)
+	}
+
+	return nil
+}
+
+// parseSignatures returns signatures pushed by the invocation script.
+func parseSignatures(script []byte) ([][]byte, error) {
+	const pushLen = 2 + keys.SignatureLen
+
+	var res [][]byte
+	for len(script) != 0 {
+		if len(script) < pushLen || script[0] != byte(opcode.PUSHDATA1) || script[1] != keys.SignatureLen {
+			return nil, errors.New("unexpected instruction")
+		}
+		res = append(res, script[2:pushLen])
+		script = script[pushLen:]
+	}
+
+	return res, nil
+}
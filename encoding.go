@@ -28,7 +28,7 @@ func PrintEvent(b *block.Block, n state.NotificationEvent, extra string) {
 	fmt.Println(s)
 }
 
-func PrintTransfer(b *block.Block, n state.NotificationEvent) {
+func PrintTransfer(b *block.Block, n state.NotificationEvent, book *addressBook) {
 	const nonCompatibleMsg = "not NEP-17 compatible"
 
 	items, ok := n.Item.Value().([]stackitem.Item)
@@ -60,11 +60,11 @@ func PrintTransfer(b *block.Block, n state.NotificationEvent) {
 
 	var sndStr, rcvStr = "nil", "nil"
 	if snd != nil {
-		sndStr = hex.EncodeToString(revertBytes(snd))
+		sndStr = book.account(snd)
 	}
 
 	if rcv != nil {
-		rcvStr = hex.EncodeToString(revertBytes(rcv))
+		rcvStr = book.account(rcv)
 	}
 
 	d := time.Unix(int64(b.Timestamp/1e3), 0)
@@ -185,7 +185,7 @@ func PrintUpdateState(b *block.Block, n state.NotificationEvent) {
 	fmt.Println(s)
 }
 
-func PrintBlock(b *block.Block, extra string) {
+func PrintBlock(b *block.Header, extra string) {
 	d := time.Unix(int64(b.Timestamp/1e3), 0)
 	s := fmt.Sprintf("block:%d at:%s", b.Index, d.Format(time.RFC3339))
 
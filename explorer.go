@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -26,6 +27,7 @@ type (
 	Explorer struct {
 		ctx      context.Context
 		chain    *chain.Chain
+		book     *addressBook
 		endpoint string
 		app      *tview.Application
 
@@ -48,27 +50,29 @@ func explorer(c *cli.Context) (err error) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 
 	// parse blockchain info
-	cacheDir := c.String(cacheFlagKey)
-	if len(cacheDir) == 0 {
-		cacheDir, err = defaultConfigDir()
-		if err != nil {
-			return err
-		}
+	blockchain, err := openChain(ctx, c, chainOptions(c))
+	if err != nil {
+		return err
 	}
 
-	endpoint := c.String(endpointFlagKey)
-	blockchain, err := chain.Open(ctx, cacheDir, endpoint)
-	if err != nil {
-		return fmt.Errorf("cannot initialize remote blockchain client: %w", err)
+	endpoint := strings.Join(c.StringSlice(endpointFlagKey), ", ")
+	if blockchain.Offline() {
+		endpoint = "offline"
 	}
 	defer func() {
-		blockchain.Close()
+		closeChain(blockchain)
 		cancel()
 	}()
 
+	book, err := openAddressBook(ctx, c, blockchain)
+	if err != nil {
+		return err
+	}
+
 	e := Explorer{
 		ctx:      ctx,
 		chain:    blockchain,
+		book:     book,
 		endpoint: endpoint,
 		app:      tview.NewApplication(),
 		jobCh:    make(chan fetchTask),
@@ -189,32 +193,31 @@ func (e *Explorer) Run() error {
 			e.app.SetFocus(blockList)
 			return
 		}
-		// Try parse as transaction hash
+		// Try parse as transaction or block hash
 		h, err := util.Uint256DecodeStringLE(input)
 		if err == nil {
-			appLog, err := e.chain.Client.GetApplicationLog(h, nil)
+			index, err := e.chain.TransactionBlock(e.ctx, h)
 			if err != nil {
-				e.errorStatusBar(searchInput, "tx hash not found")
-				return
-			}
-			block, err := e.chain.BlockByHash(appLog.Container)
-			if err != nil {
-				e.errorStatusBar(searchInput, "can't get block of specified tx")
-				return
+				block, blockErr := e.chain.BlockByHash(e.ctx, h)
+				if blockErr != nil {
+					e.errorStatusBar(searchInput, "tx or block hash not found")
+					return
+				}
+				index = block.Index
 			}
-			from, to := blockIndexRange(int(block.Index), blockCount, 50)
+			from, to := blockIndexRange(int(index), blockCount, 50)
 			e.cacheBlocks(from, to)
 			e.defaultStatusBar(searchInput, blockCount)
-			blockList.SetCurrentItem(-int(block.Index) - 1)
+			blockList.SetCurrentItem(-int(index) - 1)
 			e.app.SetFocus(blockList)
 			return
 		}
-		e.errorStatusBar(searchInput, "invalid input, expect valid block number or tx hash")
+		e.errorStatusBar(searchInput, "invalid input, expect valid block number, tx or block hash")
 	})
 
 	// Handle selecting block in block list
 	blockList.SetSelectedFunc(func(i int, s1, s2 string, r rune) {
-		block, err := e.chain.Block(uint32(blockList.GetItemCount() - i - 1))
+		block, err := e.chain.Block(e.ctx, uint32(blockList.GetItemCount() - i - 1))
 		if err != nil {
 			panic(err)
 		}
@@ -230,7 +233,7 @@ func (e *Explorer) Run() error {
 		if err != nil {
 			panic(err)
 		}
-		appLog, err := e.chain.ApplicationLog(txHash)
+		appLog, err := e.chain.ApplicationLog(e.ctx, txHash)
 		if err != nil {
 			panic(err)
 		}
@@ -242,7 +245,7 @@ func (e *Explorer) Run() error {
 
 		var res string
 		for _, event := range events {
-			v, err := formatNotification(event)
+			v, err := formatNotification(event, e.book)
 			if err != nil {
 				continue
 			}
@@ -262,7 +265,7 @@ func (e *Explorer) Run() error {
 }
 
 func (e *Explorer) fillBlockList(list *tview.List) {
-	to, err := e.chain.Client.GetBlockCount()
+	to, err := e.chain.BlockCount(e.ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -285,7 +288,7 @@ func (e *Explorer) fillBlockList(list *tview.List) {
 func (e *Explorer) refillBlockList(list *tview.List) {
 	from := uint32(list.GetItemCount())
 
-	to, err := e.chain.Client.GetBlockCount()
+	to, err := e.chain.BlockCount(e.ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -323,7 +326,7 @@ func (e *Explorer) redrawBlockList(list *tview.List, height int) {
 			continue
 		}
 		blockIndex := toBlockIndex(i, itemCount)
-		block, err := e.chain.Block(blockIndex)
+		block, err := e.chain.Block(e.ctx, blockIndex)
 		if err != nil {
 			panic(err)
 		}
@@ -345,12 +348,15 @@ func (e *Explorer) startWorkers(amount int) {
 				}
 				var err error
 				if task.txHash != nil {
-					_, err = e.chain.ApplicationLog(*task.txHash)
+					_, err = e.chain.ApplicationLog(ctx, *task.txHash)
 				} else {
-					_, err = e.chain.Block(task.index)
+					_, err = e.chain.Block(ctx, task.index)
 				}
 				if err != nil {
-					out <- err
+					select {
+					case out <- err:
+					case <-ctx.Done():
+					}
 					return
 				}
 				e.wg.Done()
@@ -483,17 +489,54 @@ func blockIndexRange(index, length, delta int) (uint32, uint32) {
 	return uint32(from), uint32(to)
 }
 
-func formatNotification(event state.NotificationEvent) (string, error) {
+func formatNotification(event state.NotificationEvent, book *addressBook) (string, error) {
 	data, err := stackitem.ToJSONWithTypes(event.Item)
 	if err != nil {
 		return "", err
 	}
+
+	var item interface{}
+	if err = json.Unmarshal(data, &item); err != nil {
+		return "", err
+	}
+	formatAccounts(item, book)
+
+	data, err = json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+
 	var formatted bytes.Buffer
 	err = json.Indent(&formatted, data, "", "   ")
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s\n---\n%s\n\n", event.Name, formatted.String()), nil
+	return fmt.Sprintf("%s (%s)\n---\n%s\n\n", event.Name, book.name(event.ScriptHash), formatted.String()), nil
+}
+
+// formatAccounts replaces base64 values of byte strings of script hash size
+// in JSON of the stack item with accounts formatted by the address book.
+func formatAccounts(item interface{}, book *addressBook) {
+	switch v := item.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			formatAccounts(elem, book)
+		}
+	case map[string]interface{}:
+		switch v["type"] {
+		case stackitem.ByteArrayT.String(), stackitem.BufferT.String():
+			s, _ := v["value"].(string)
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err == nil && len(data) == util.Uint160Size {
+				v["value"] = book.account(data)
+			}
+		default:
+			// arrays, structs and key-value pairs of maps
+			for _, elem := range v {
+				formatAccounts(elem, book)
+			}
+		}
+	}
 }
 
 func min(a, b int) int {
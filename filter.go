@@ -0,0 +1,1006 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/bigint"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+
+	"github.com/alexvanin/monza/chain"
+)
+
+// Filter expression selects notification events by their fields, e.g.
+//
+//	name == "Transfer" && contract == "gas" && amount > 1000000000
+//
+// Fields of the event are
+//
+//	name      name of the notification
+//	contract  script hash of the contract
+//	block     index of the block
+//	time      timestamp of the block in milliseconds
+//	tx        hash of the transaction or the block for OnPersist and
+//	          PostPersist triggers
+//	trigger   OnPersist, Application or PostPersist
+//	arg[N]    argument of the event at N position
+//	<param>   argument of the event named in the contract manifest
+//
+// Literals are integers, "strings", true, false, null, N3 addresses,
+// LE script hashes and transaction hashes, and 0x-prefixed hex of byte
+// strings, so 0x-prefixed script hashes and transaction hashes are BE.
+// Contract names such as "gas" are compared with contract, RFC3339
+// strings are compared with time.
+//
+// Operators are ==, !=, <, <=, >, >=, in (...), not in (...), &&, ||, !
+// and their word forms and, or, not. Arguments are compared with
+// the semantics of NeoVM: byte strings are compared with integers as
+// little endian numbers and with strings as UTF-8 text. Comparison of
+// values of incompatible types is false.
+type filter struct {
+	root       filterNode
+	blockchain *chain.Chain
+}
+
+// filterEnv is the event being matched.
+type filterEnv struct {
+	ctx        context.Context
+	blockchain *chain.Chain
+	block      *block.Block
+	event      chain.Event
+
+	// params are names of event arguments, resolved on demand
+	params   []string
+	resolved bool
+}
+
+type filterNode interface {
+	eval(env *filterEnv) (filterValue, error)
+}
+
+type valueKind int
+
+const (
+	kindNull valueKind = iota
+	kindBool
+	kindInt
+	kindString
+	kindBytes
+	// kindOther is a compound stack item which is not comparable
+	kindOther
+)
+
+type filterValue struct {
+	kind valueKind
+	b    bool
+	i    *big.Int
+	s    string
+	data []byte
+}
+
+var nullValue = filterValue{kind: kindNull}
+
+func boolValue(b bool) filterValue { return filterValue{kind: kindBool, b: b} }
+
+func intValue(i *big.Int) filterValue { return filterValue{kind: kindInt, i: i} }
+
+func stringValue(s string) filterValue { return filterValue{kind: kindString, s: s} }
+
+func bytesValue(data []byte) filterValue { return filterValue{kind: kindBytes, data: data} }
+
+// Fields of the event.
+const (
+	fieldName     = "name"
+	fieldContract = "contract"
+	fieldBlock    = "block"
+	fieldTime     = "time"
+	fieldTx       = "tx"
+	fieldTrigger  = "trigger"
+	fieldArg      = "arg"
+)
+
+// parseFilter parses filter expression, it returns nil filter for empty
+// expression.
+func parseFilter(ctx context.Context, expr string, blockchain *chain.Chain, book *addressBook) (*filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	p := &filterParser{ctx: ctx, book: book, tokens: tokens}
+
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	return &filter{root: root, blockchain: blockchain}, nil
+}
+
+// match returns true if the event of the block is selected by the filter.
+func (f *filter) match(ctx context.Context, b *block.Block, ev chain.Event) (bool, error) {
+	env := &filterEnv{
+		ctx:        ctx,
+		blockchain: f.blockchain,
+		block:      b,
+		event:      ev,
+	}
+
+	v, err := f.root.eval(env)
+	if err != nil {
+		return false, fmt.Errorf("cannot filter notification %s of block %d: %w", ev.Name, b.Index, err)
+	}
+
+	return v.truth(), nil
+}
+
+// notifications returns matcher of notification names which may be
+// selected by the filter, so they can be searched with the notification
+// index. It returns nil if any name may be selected.
+func (f *filter) notifications() *notificationMatcher {
+	names, ok := filterNames(f.root)
+	if !ok || len(names) == 0 {
+		return nil
+	}
+
+	res := &notificationMatcher{include: make([]notificationPattern, 0, len(names))}
+	for name := range names {
+		res.include = append(res.include, notificationPattern{name: escapePattern(name)})
+	}
+
+	return res
+}
+
+// escapePattern returns pattern of path.Match which matches only the name.
+func escapePattern(name string) string {
+	var sb strings.Builder
+	for _, c := range name {
+		if strings.ContainsRune(`*?[\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+
+	return sb.String()
+}
+
+// filterNames returns the set of notification names selected by the node,
+// ok is false if the set is not limited.
+func filterNames(n filterNode) (names map[string]struct{}, ok bool) {
+	switch n := n.(type) {
+	case *andNode:
+		left, lok := filterNames(n.left)
+		right, rok := filterNames(n.right)
+		switch {
+		case lok && rok:
+			res := make(map[string]struct{})
+			for name := range left {
+				if _, ok := right[name]; ok {
+					res[name] = struct{}{}
+				}
+			}
+			return res, true
+		case lok:
+			return left, true
+		default:
+			return right, rok
+		}
+	case *orNode:
+		left, lok := filterNames(n.left)
+		right, rok := filterNames(n.right)
+		if !lok || !rok {
+			return nil, false
+		}
+		for name := range right {
+			left[name] = struct{}{}
+		}
+		return left, true
+	case *compareNode:
+		if n.op != tokenEq {
+			return nil, false
+		}
+		name, ok := nameLiteral(n.left, n.right)
+		if !ok {
+			name, ok = nameLiteral(n.right, n.left)
+		}
+		if !ok {
+			return nil, false
+		}
+		return map[string]struct{}{name: {}}, true
+	case *inNode:
+		if n.negate {
+			return nil, false
+		}
+		res := make(map[string]struct{}, len(n.list))
+		for _, item := range n.list {
+			name, ok := nameLiteral(n.value, item)
+			if !ok {
+				return nil, false
+			}
+			res[name] = struct{}{}
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+func nameLiteral(field, literal filterNode) (string, bool) {
+	f, ok := field.(*fieldNode)
+	if !ok || f.name != fieldName {
+		return "", false
+	}
+
+	l, ok := literal.(*literalNode)
+	if !ok || l.value.kind != kindString {
+		return "", false
+	}
+
+	return l.value.s, true
+}
+
+func (v filterValue) truth() bool {
+	switch v.kind {
+	case kindBool:
+		return v.b
+	case kindInt:
+		return v.i.Sign() != 0
+	case kindString:
+		return v.s != ""
+	case kindBytes:
+		return len(v.data) != 0
+	case kindOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// integer converts the value to integer the way NeoVM does.
+func (v filterValue) integer() (*big.Int, bool) {
+	switch v.kind {
+	case kindInt:
+		return v.i, true
+	case kindBytes:
+		switch {
+		case len(v.data) > stackitem.MaxBigIntegerSizeBits/8:
+			return nil, false
+		case len(v.data) == 0:
+			// FromBytes does not accept nil byte strings
+			return big.NewInt(0), true
+		}
+		return bigint.FromBytes(v.data), true
+	case kindBool:
+		if v.b {
+			return big.NewInt(1), true
+		}
+		return big.NewInt(0), true
+	default:
+		return nil, false
+	}
+}
+
+// text returns byte representation of strings and byte strings.
+func (v filterValue) text() ([]byte, bool) {
+	switch v.kind {
+	case kindString:
+		return []byte(v.s), true
+	case kindBytes:
+		return v.data, true
+	default:
+		return nil, false
+	}
+}
+
+// compareValues returns the result of comparison of values, ok is false if they
+// are incomparable.
+func compareValues(a, b filterValue) (res int, ok bool) {
+	switch {
+	case a.kind == kindNull || b.kind == kindNull:
+		if a.kind == b.kind {
+			return 0, true
+		}
+		return 0, false
+	case a.kind == kindOther || b.kind == kindOther:
+		return 0, false
+	case a.kind == kindBool && b.kind == kindBool:
+		switch {
+		case a.b == b.b:
+			return 0, true
+		case b.b:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case a.kind == kindInt || b.kind == kindInt || a.kind == kindBool || b.kind == kindBool:
+		x, xok := a.integer()
+		y, yok := b.integer()
+		if !xok || !yok {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	default:
+		x, _ := a.text()
+		y, _ := b.text()
+		return bytes.Compare(x, y), true
+	}
+}
+
+func itemValue(item stackitem.Item) filterValue {
+	switch item.Type() {
+	case stackitem.AnyT:
+		return nullValue
+	case stackitem.BooleanT:
+		return boolValue(item.Value().(bool))
+	case stackitem.IntegerT:
+		return intValue(item.Value().(*big.Int))
+	case stackitem.ByteArrayT, stackitem.BufferT:
+		data, err := item.TryBytes()
+		if err != nil {
+			return filterValue{kind: kindOther}
+		}
+		return bytesValue(data)
+	default:
+		return filterValue{kind: kindOther}
+	}
+}
+
+type literalNode struct {
+	value filterValue
+}
+
+func (n *literalNode) eval(*filterEnv) (filterValue, error) {
+	return n.value, nil
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(env *filterEnv) (filterValue, error) {
+	switch n.name {
+	case fieldName:
+		return stringValue(env.event.Name), nil
+	case fieldContract:
+		return bytesValue(env.event.ScriptHash.BytesBE()), nil
+	case fieldBlock:
+		return intValue(new(big.Int).SetUint64(uint64(env.block.Index))), nil
+	case fieldTime:
+		return intValue(new(big.Int).SetUint64(env.block.Timestamp)), nil
+	case fieldTx:
+		return bytesValue(env.event.Container.BytesBE()), nil
+	case fieldTrigger:
+		return stringValue(env.event.Trigger.String()), nil
+	default:
+		return nullValue, fmt.Errorf("unknown field %s", n.name)
+	}
+}
+
+// argNode is an argument of the event referenced by position or by name.
+type argNode struct {
+	index int
+	name  string
+}
+
+func (n *argNode) eval(env *filterEnv) (filterValue, error) {
+	items, ok := env.event.Item.Value().([]stackitem.Item)
+	if !ok {
+		return nullValue, nil
+	}
+
+	index := n.index
+	if n.name != "" {
+		params, err := env.eventParameters()
+		if err != nil {
+			return nullValue, err
+		}
+
+		index = -1
+		for i, p := range params {
+			if p == n.name {
+				index = i
+				break
+			}
+		}
+	}
+
+	if index < 0 || index >= len(items) {
+		return nullValue, nil
+	}
+
+	return itemValue(items[index]), nil
+}
+
+// standardEvents are parameters of events defined by NEP standards. They
+// are used when contract manifests are not available in offline mode.
+var standardEvents = map[string][][]string{
+	"Transfer": {
+		{"from", "to", "amount"},            // NEP-17
+		{"from", "to", "amount", "tokenId"}, // NEP-11
+	},
+}
+
+func (env *filterEnv) eventParameters() ([]string, error) {
+	if env.resolved {
+		return env.params, nil
+	}
+
+	ev := env.event
+	params, err := env.blockchain.EventParameters(env.ctx, ev.ScriptHash, ev.Name)
+	if errors.Is(err, chain.ErrNotCached) {
+		items, _ := ev.Item.Value().([]stackitem.Item)
+		for _, std := range standardEvents[ev.Name] {
+			if len(std) == len(items) {
+				params, err = std, nil
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parameter names of %s are unknown in offline mode, use arg[N]", ev.Name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	env.params, env.resolved = params, true
+
+	return params, nil
+}
+
+type notNode struct {
+	expr filterNode
+}
+
+func (n *notNode) eval(env *filterEnv) (filterValue, error) {
+	v, err := n.expr.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+
+	return boolValue(!v.truth()), nil
+}
+
+type andNode struct {
+	left, right filterNode
+}
+
+func (n *andNode) eval(env *filterEnv) (filterValue, error) {
+	v, err := n.left.eval(env)
+	if err != nil || !v.truth() {
+		return boolValue(false), err
+	}
+
+	v, err = n.right.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+
+	return boolValue(v.truth()), nil
+}
+
+type orNode struct {
+	left, right filterNode
+}
+
+func (n *orNode) eval(env *filterEnv) (filterValue, error) {
+	v, err := n.left.eval(env)
+	if err != nil || v.truth() {
+		return boolValue(true), err
+	}
+
+	v, err = n.right.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+
+	return boolValue(v.truth()), nil
+}
+
+type compareNode struct {
+	op          tokenKind
+	left, right filterNode
+}
+
+func (n *compareNode) eval(env *filterEnv) (filterValue, error) {
+	a, err := n.left.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+
+	b, err := n.right.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+
+	res, ok := compareValues(a, b)
+	switch n.op {
+	case tokenEq:
+		return boolValue(ok && res == 0), nil
+	case tokenNe:
+		return boolValue(!ok || res != 0), nil
+	case tokenLt:
+		return boolValue(ok && res < 0), nil
+	case tokenLe:
+		return boolValue(ok && res <= 0), nil
+	case tokenGt:
+		return boolValue(ok && res > 0), nil
+	case tokenGe:
+		return boolValue(ok && res >= 0), nil
+	default:
+		return nullValue, fmt.Errorf("unknown operator %d", n.op)
+	}
+}
+
+type inNode struct {
+	value  filterNode
+	list   []filterNode
+	negate bool
+}
+
+func (n *inNode) eval(env *filterEnv) (filterValue, error) {
+	a, err := n.value.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+
+	for _, item := range n.list {
+		b, err := item.eval(env)
+		if err != nil {
+			return nullValue, err
+		}
+
+		if res, ok := compareValues(a, b); ok && res == 0 {
+			return boolValue(!n.negate), nil
+		}
+	}
+
+	return boolValue(n.negate), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenMinus
+	tokenEq
+	tokenNe
+	tokenLt
+	tokenLe
+	tokenGt
+	tokenGe
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at %d", t.text, t.pos+1)
+}
+
+// operators are ordered so the longest operator is matched first.
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"==", tokenEq}, {"!=", tokenNe}, {"<=", tokenLe}, {">=", tokenGe},
+	{"&&", tokenAnd}, {"||", tokenOr},
+	{"=", tokenEq}, {"<", tokenLt}, {">", tokenGt}, {"!", tokenNot},
+	{"(", tokenLParen}, {")", tokenRParen}, {"[", tokenLBracket}, {"]", tokenRBracket},
+	{",", tokenComma}, {"-", tokenMinus},
+}
+
+func tokenizeFilter(expr string) ([]token, error) {
+	var res []token
+
+	for pos := 0; pos < len(expr); {
+		c := rune(expr[pos])
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '"' || c == '\'':
+			end := pos + 1
+			for end < len(expr) && rune(expr[end]) != c {
+				if c == '"' && expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", pos+1)
+			}
+
+			raw := expr[pos : end+1]
+			s := raw[1 : len(raw)-1]
+			if c == '"' {
+				var err error
+				if s, err = strconv.Unquote(raw); err != nil {
+					return nil, fmt.Errorf("invalid string at %d", pos+1)
+				}
+			}
+
+			res = append(res, token{kind: tokenString, text: s, pos: pos})
+			pos = end + 1
+		case isWordChar(c):
+			end := pos
+			for end < len(expr) && isWordChar(rune(expr[end])) {
+				end++
+			}
+
+			word := expr[pos:end]
+			kind := tokenWord
+			switch word {
+			case "and":
+				kind = tokenAnd
+			case "or":
+				kind = tokenOr
+			case "not":
+				kind = tokenNot
+			}
+
+			res = append(res, token{kind: kind, text: word, pos: pos})
+			pos = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(expr[pos:], op.text) {
+					res = append(res, token{kind: op.kind, text: op.text, pos: pos})
+					pos += len(op.text)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, pos+1)
+			}
+		}
+	}
+
+	return append(res, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c))
+}
+
+// filterParser is a recursive descent parser of filter expressions:
+//
+//	or      = and { "||" and }
+//	and     = not { "&&" not }
+//	not     = "!" not | compare
+//	compare = operand [ op operand | [ "!" ] "in" "(" operand { "," operand } ")" ]
+//	operand = "(" or ")" | literal | field
+type filterParser struct {
+	ctx    context.Context
+	book   *addressBook
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *filterParser) expect(kind tokenKind, what string) error {
+	if t := p.next(); t.kind != kind {
+		return fmt.Errorf("expected %s, got %s", what, t)
+	}
+
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.next()
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if p.peek().kind != tokenNot {
+		return p.parseCompare()
+	}
+	p.next()
+
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &notNode{expr: expr}, nil
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := p.peek(); t.kind {
+	case tokenEq, tokenNe, tokenLt, tokenLe, tokenGt, tokenGe:
+		p.next()
+
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		if left, err = p.convert(left, right); err != nil {
+			return nil, err
+		}
+
+		if right, err = p.convert(right, left); err != nil {
+			return nil, err
+		}
+
+		return &compareNode{op: t.kind, left: left, right: right}, nil
+	case tokenNot, tokenWord:
+		negate := t.kind == tokenNot
+		if negate {
+			p.next()
+		}
+
+		if t := p.next(); t.kind != tokenWord || t.text != "in" {
+			return nil, fmt.Errorf("expected in operator, got %s", t)
+		}
+
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range list {
+			if list[i], err = p.convert(list[i], left); err != nil {
+				return nil, err
+			}
+		}
+
+		return &inNode{value: left, list: list, negate: negate}, nil
+	default:
+		return left, nil
+	}
+}
+
+func (p *filterParser) parseList() ([]filterNode, error) {
+	if err := p.expect(tokenLParen, "list of values"); err != nil {
+		return nil, err
+	}
+
+	var res []filterNode
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+
+		switch t := p.next(); t.kind {
+		case tokenComma:
+		case tokenRParen:
+			return res, nil
+		default:
+			return nil, fmt.Errorf("expected , or ), got %s", t)
+		}
+	}
+}
+
+func (p *filterParser) parseOperand() (filterNode, error) {
+	switch t := p.next(); t.kind {
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return expr, p.expect(tokenRParen, ")")
+	case tokenString:
+		return &literalNode{value: stringValue(t.text)}, nil
+	case tokenMinus:
+		w := p.next()
+		v, ok := new(big.Int).SetString(w.text, 10)
+		if w.kind != tokenWord || !ok {
+			return nil, fmt.Errorf("expected integer, got %s", w)
+		}
+
+		return &literalNode{value: intValue(v.Neg(v))}, nil
+	case tokenWord:
+		return p.parseWord(t)
+	default:
+		return nil, fmt.Errorf("expected value, got %s", t)
+	}
+}
+
+// parseWord parses field or literal which is not a string.
+func (p *filterParser) parseWord(t token) (filterNode, error) {
+	const (
+		uint160Len = 2 * util.Uint160Size
+		uint256Len = 2 * util.Uint256Size
+	)
+
+	word := t.text
+
+	switch word {
+	case "true", "false":
+		return &literalNode{value: boolValue(word == "true")}, nil
+	case "null":
+		return &literalNode{value: nullValue}, nil
+	case fieldName, fieldContract, fieldBlock, fieldTime, fieldTx, fieldTrigger:
+		return &fieldNode{name: word}, nil
+	case fieldArg:
+		if err := p.expect(tokenLBracket, "["); err != nil {
+			return nil, err
+		}
+
+		i := p.next()
+		index, err := strconv.ParseUint(i.text, 10, 16)
+		if i.kind != tokenWord || err != nil {
+			return nil, fmt.Errorf("expected argument position, got %s", i)
+		}
+
+		return &argNode{index: int(index)}, p.expect(tokenRBracket, "]")
+	}
+
+	// 0x-prefixed hex is in the byte order of stack items, so script
+	// hashes and transaction hashes are big endian like --addr-format be
+	// prints them
+	if strings.HasPrefix(word, "0x") {
+		data, err := hex.DecodeString(word[2:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex %s", t)
+		}
+		return &literalNode{value: bytesValue(data)}, nil
+	}
+
+	if isHex(word) {
+		switch len(word) {
+		case uint160Len:
+			u, err := util.Uint160DecodeStringLE(word)
+			if err == nil {
+				return &literalNode{value: bytesValue(u.BytesBE())}, nil
+			}
+		case uint256Len:
+			u, err := util.Uint256DecodeStringLE(word)
+			if err == nil {
+				return &literalNode{value: bytesValue(u.BytesBE())}, nil
+			}
+		}
+	}
+
+	if v, ok := new(big.Int).SetString(word, 10); ok {
+		return &literalNode{value: intValue(v)}, nil
+	}
+
+	if u, err := address.StringToUint160(word); err == nil {
+		return &literalNode{value: bytesValue(u.BytesBE())}, nil
+	}
+
+	if !unicode.IsLetter(rune(word[0])) && word[0] != '_' {
+		return nil, fmt.Errorf("invalid value %s", t)
+	}
+
+	return &argNode{name: word}, nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// convert returns string literal of the node converted to the type of the
+// field it is compared with: contract name to script hash, RFC3339 time to
+// timestamp and trigger name to its canonical form.
+func (p *filterParser) convert(n, field filterNode) (filterNode, error) {
+	l, ok := n.(*literalNode)
+	if !ok || l.value.kind != kindString {
+		return n, nil
+	}
+
+	f, ok := field.(*fieldNode)
+	if !ok {
+		return n, nil
+	}
+
+	switch s := l.value.s; f.name {
+	case fieldContract:
+		u, err := p.book.resolve(p.ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: bytesValue(u.BytesBE())}, nil
+	case fieldTime:
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %s, use RFC3339 format", s)
+		}
+		return &literalNode{value: intValue(big.NewInt(ts.UnixMilli()))}, nil
+	case fieldTrigger:
+		tr, err := trigger.FromString(s)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: stringValue(tr.String())}, nil
+	default:
+		return n, nil
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+
+	"github.com/alexvanin/monza/chain"
+)
+
+var (
+	testGas  = util.Uint160{0xcf, 0x76, 0xe2, 0x8b, 0xd0, 0x06, 0x2c, 0x4a, 0x47, 0x8e, 0xe3, 0x55, 0x61, 0x01, 0x13, 0x19, 0xf3, 0xcf, 0xa4, 0xd2}
+	testFrom = util.Uint160{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	testTx   = util.Uint256{0xaa, 0xbb, 0xcc, 31: 0xff}
+)
+
+// testBook returns address book without chain with GAS contract only.
+func testBook() *addressBook {
+	a := &addressBook{
+		format: addrFormatLE,
+		hashes: make(map[string]util.Uint160),
+		names:  make(map[util.Uint160]string),
+	}
+	a.add("GasToken", testGas)
+	a.add("gas", testGas)
+
+	return a
+}
+
+// testEvent returns GAS transfer of 100 from testFrom to nobody in
+// transaction testTx of block 10.
+func testEvent() (*block.Block, chain.Event) {
+	b := block.New(false)
+	b.Index = 10
+	b.Timestamp = 1646092800000 // 2022-03-01T00:00:00Z
+
+	ev := chain.Event{
+		NotificationEvent: state.NotificationEvent{
+			ScriptHash: testGas,
+			Name:       "Transfer",
+			Item: stackitem.NewArray([]stackitem.Item{
+				stackitem.NewByteArray(testFrom.BytesBE()),
+				stackitem.Null{},
+				stackitem.NewBigInteger(big.NewInt(100)),
+				stackitem.NewByteArray([]byte("memo")),
+				stackitem.NewBool(true),
+				stackitem.NewBigInteger(big.NewInt(-5)),
+				stackitem.NewArray(nil),
+			}),
+		},
+		Container: testTx,
+		Trigger:   trigger.Application,
+	}
+
+	return b, ev
+}
+
+func TestFilterMatch(t *testing.T) {
+	b, ev := testEvent()
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		// fields
+		{`name == "Transfer"`, true},
+		{`name = 'Transfer'`, true},
+		{`name != "Transfer"`, false},
+		{`block == 10 && time == "2022-03-01T00:00:00Z"`, true},
+		{`block > 9 && block < 11 && block >= 10 && block <= 10`, true},
+		{`trigger == "application"`, true},
+		{`trigger == "OnPersist"`, false},
+
+		// precedence: ! binds tighter than &&, && binds tighter than ||
+		{`name == "Transfer" || block == 1 && block == 2`, true},
+		{`(name == "Transfer" || block == 1) && block == 2`, false},
+		{`block == 1 && block == 2 || name == "Transfer"`, true},
+		{`!name == "Transfer"`, false},
+		{`!(name == "Transfer") || block == 10`, true},
+		{`!block == 1 && block == 10`, true},
+		{`!!(block == 10)`, true},
+		{`not block == 10 or block == 10 and not block == 1`, true},
+
+		// in and not in
+		{`name in ("Mint", "Transfer")`, true},
+		{`name in ("Mint", "Burn")`, false},
+		{`name not in ("Mint", "Burn")`, true},
+		{`name !in ("Mint", "Transfer")`, false},
+		{`block in (1, 10, -10)`, true},
+		{`arg[2] in ("d", 100)`, true},
+
+		// negative numbers
+		{`arg[5] == -5`, true},
+		{`arg[5] < -4 && arg[5] > -6`, true},
+		{`arg[5] == 5`, false},
+		{`-5 == arg[5]`, true},
+
+		// quoted strings
+		{`arg[3] == "memo"`, true},
+		{`arg[3] == 'memo'`, true},
+		{`arg[3] == "me\x6do"`, true},
+		{`arg[3] == 'me\x6do'`, false},
+		{`arg[3] < "mf" && arg[3] > "mem"`, true},
+		{`name == "Transfer && block == 1"`, false},
+
+		// hash and address literals
+		{`contract == "gas"`, true},
+		{`contract == "GasToken"`, true},
+		{`contract == d2a4cff31913016155e38e474a2c06d08be276cf`, true},
+		{`contract == 0xd2a4cff31913016155e38e474a2c06d08be276cf`, false},
+		{`contract == cf76e28bd0062c4a478ee35561011319f3cfa4d2`, false},
+		{`contract == 0xcf76e28bd0062c4a478ee35561011319f3cfa4d2`, true},
+		{`contract in ("gas", 0x0000000000000000000000000000000000000000)`, true},
+		{`arg[0] == ` + address.Uint160ToString(testFrom), true},
+		{`arg[0] == ` + testFrom.StringLE(), true},
+		{`arg[0] == 0x` + testFrom.StringLE(), false},
+		{`arg[0] == 0x` + testFrom.StringBE(), true},
+		{`tx == ` + testTx.StringLE(), true},
+		{`tx == 0x` + testTx.StringLE(), false},
+		{`tx == 0x` + testTx.StringBE(), true},
+
+		// hex of other lengths is a byte string
+		{`arg[3] == 0x6d656d6f`, true},
+		{`arg[2] == 0x64`, true},
+
+		// other values
+		{`arg[1] == null`, true},
+		{`arg[1] != null`, false},
+		{`arg[7] == null`, true},
+		{`arg[4] == true && arg[4] == 1`, true},
+		{`arg[6] == arg[6]`, false},
+		{`arg[6]`, true},
+		{`arg[1]`, false},
+		{`arg[2]`, true},
+	}
+
+	for _, tc := range tests {
+		f, err := parseFilter(context.Background(), tc.expr, nil, testBook())
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+
+		match, err := f.match(context.Background(), b, ev)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+
+		if match != tc.match {
+			t.Errorf("%s: expected %t, got %t", tc.expr, tc.match, match)
+		}
+	}
+}
+
+func TestFilterParseError(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{`name == "Transfer`, "unterminated string"},
+		{`name == "\q"`, "invalid string"},
+		{`name == "Transfer" &&`, "expected value"},
+		{`(name == "Transfer"`, "expected )"},
+		{`name == "Transfer")`, "unexpected"},
+		{`name in "Transfer"`, "expected list of values"},
+		{`name in ("Transfer" "Mint")`, "expected , or )"},
+		{`name is "Transfer"`, "expected in operator"},
+		{`block == -x`, "expected integer"},
+		{`arg[x] == 1`, "expected argument position"},
+		{`arg[1 == 1`, "expected ]"},
+		{`block == 0xzz`, "invalid hex"},
+		{`block == 1x`, "invalid value"},
+		{`time > "yesterday"`, "invalid time"},
+		{`trigger == "Never"`, "unknown trigger"},
+		{`contract == "nns"`, "invalid contract name"},
+		{`block # 1`, "unexpected character"},
+	}
+
+	for _, tc := range tests {
+		_, err := parseFilter(context.Background(), tc.expr, nil, testBook())
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected error with %q, got %v", tc.expr, tc.err, err)
+		}
+	}
+}
+
+func TestFilterNotifications(t *testing.T) {
+	tests := []struct {
+		expr  string
+		names []string // nil if names are not limited
+	}{
+		{`name == "Transfer"`, []string{"Transfer"}},
+		{`"Transfer" == name`, []string{"Transfer"}},
+		{`name == "Transfer" && block > 10`, []string{"Transfer"}},
+		{`block > 10 && name == "Transfer"`, []string{"Transfer"}},
+		{`name == "Transfer" || name == "Mint"`, []string{"Mint", "Transfer"}},
+		{`name in ("Transfer", "Mint") && name in ("Mint", "Burn")`, []string{"Mint"}},
+		{`name in ("Transfer", "Mint") && name == "Burn"`, []string{}},
+		{`(name == "Transfer" || name == "Mint") && amount > 1`, []string{"Mint", "Transfer"}},
+		{`name == "a*[b]?"`, []string{`a\*\[b]\?`}},
+		{`name == "Transfer" || block > 10`, nil},
+		{`name != "Transfer"`, nil},
+		{`name not in ("Transfer")`, nil},
+		{`!(name == "Transfer")`, nil},
+		{`name in ("Transfer", 1)`, nil},
+		{`name == arg[0]`, nil},
+		{`block > 10`, nil},
+	}
+
+	for _, tc := range tests {
+		f, err := parseFilter(context.Background(), tc.expr, nil, testBook())
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+
+		m := f.notifications()
+		switch {
+		case tc.names == nil && m != nil:
+			t.Errorf("%s: expected any name, got %v", tc.expr, m.include)
+			continue
+		case tc.names == nil:
+			continue
+		case len(tc.names) == 0:
+			// the filter selects nothing, so the index is not narrowed
+			if m != nil {
+				t.Errorf("%s: expected any name, got %v", tc.expr, m.include)
+			}
+			continue
+		case m == nil:
+			t.Errorf("%s: expected %v, got any name", tc.expr, tc.names)
+			continue
+		}
+
+		names := make([]string, 0, len(m.include))
+		for _, p := range m.include {
+			names = append(names, p.name)
+		}
+		sort.Strings(names)
+
+		if strings.Join(names, ",") != strings.Join(tc.names, ",") {
+			t.Errorf("%s: expected %v, got %v", tc.expr, tc.names, names)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b filterValue
+		res  int
+		ok   bool
+	}{
+		{"null and null", nullValue, nullValue, 0, true},
+		{"null and int", nullValue, intValue(big.NewInt(0)), 0, false},
+		{"bytes and null", bytesValue(nil), nullValue, 0, false},
+		{"other", filterValue{kind: kindOther}, filterValue{kind: kindOther}, 0, false},
+		{"bools", boolValue(false), boolValue(true), -1, true},
+		{"bool and int", boolValue(true), intValue(big.NewInt(1)), 0, true},
+		{"bool and bytes", boolValue(true), bytesValue([]byte{1}), 0, true},
+		{"bool and string", boolValue(true), stringValue("a"), 0, false},
+		{"ints", intValue(big.NewInt(-2)), intValue(big.NewInt(1)), -1, true},
+		{"int and LE bytes", intValue(big.NewInt(256)), bytesValue([]byte{0, 1}), 0, true},
+		{"int and negative bytes", intValue(big.NewInt(-1)), bytesValue([]byte{0xff}), 0, true},
+		{"int and empty bytes", intValue(big.NewInt(0)), bytesValue(nil), 0, true},
+		{"int and long bytes", intValue(big.NewInt(0)), bytesValue(make([]byte, 33)), 0, false},
+		{"int and string", intValue(big.NewInt(1)), stringValue("1"), 0, false},
+		{"strings", stringValue("a"), stringValue("b"), -1, true},
+		{"string and bytes", stringValue("b"), bytesValue([]byte("a")), 1, true},
+		{"bytes", bytesValue([]byte{1, 2}), bytesValue([]byte{1, 2}), 0, true},
+	}
+
+	for _, tc := range tests {
+		res, ok := compareValues(tc.a, tc.b)
+		if res != tc.res || ok != tc.ok {
+			t.Errorf("%s: expected %d, %t, got %d, %t", tc.name, tc.res, tc.ok, res, ok)
+		}
+	}
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
-	"github.com/nspcc-dev/neo-go/pkg/rpcclient"
-	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/alexvanin/monza/chain"
 	"github.com/urfave/cli/v2"
 )
 
@@ -21,14 +21,29 @@ const (
 	workersFlagKey            = "workers"
 	disableProgressBarFlagKey = "disable-progress-bar"
 	stutterThresholdFlagKey   = "threshold"
+	networkFlagKey            = "network"
+	verifyFlagKey             = "verify"
+	offlineFlagKey            = "offline"
+	timeoutFlagKey            = "timeout"
+	retriesFlagKey            = "retries"
+	retryDelayFlagKey         = "retry-delay"
+	rpsFlagKey                = "rps"
+	disableFlagKey            = "disable"
+	lockTimeoutFlagKey        = "lock-timeout"
+	bulkFlagKey               = "bulk"
+	noSyncFlagKey             = "no-sync"
+	archiveFlagKey            = "archive"
+	followFlagKey             = "follow"
+	filterFlagKey             = "filter"
+	aliasesFlagKey            = "aliases"
+	addrFormatFlagKey         = "addr-format"
 )
 
 var (
-	endpointFlag = &cli.StringFlag{
-		Name:     endpointFlagKey,
-		Aliases:  []string{"r"},
-		Usage:    "N3 RPC endpoint",
-		Required: true,
+	endpointFlag = &cli.StringSliceFlag{
+		Name:    endpointFlagKey,
+		Aliases: []string{"r"},
+		Usage:   "N3 RPC endpoint, specify several times for failover (required unless --offline is set)",
 	}
 
 	fromFlag = &cli.StringFlag{
@@ -48,11 +63,27 @@ var (
 	notificationFlag = &cli.StringSliceFlag{
 		Name:     notificationFlagKey,
 		Aliases:  []string{"n"},
-		Usage:    "'notification:contract' pair (specify LE script hash, '*' for any contract or 'gas' and 'neo' strings)",
-		Required: true,
+		Usage:    "'notification:contract' pattern (specify N3 address, LE or 0x-prefixed BE script hash, '*' for any contract, native contract name, alias or NNS name), notification name may be a glob, e.g. 'Put*', prefix '!' excludes matched notifications",
+		Required: false,
 		Value:    nil,
 	}
 
+	aliasesFlag = &cli.StringFlag{
+		Name:  aliasesFlagKey,
+		Usage: "JSON file with contract names and their addresses or script hashes (default: <magic>.aliases.json in the cache directory)",
+	}
+
+	addrFormatFlag = &cli.StringFlag{
+		Name:  addrFormatFlagKey,
+		Usage: "format of accounts and contracts in the output: 'address', 'le' script hash or '0x'-prefixed 'be' script hash",
+		Value: addrFormatLE,
+	}
+
+	filterFlag = &cli.StringFlag{
+		Name:  filterFlagKey,
+		Usage: "expression over notification fields and arguments, e.g. 'name == \"Transfer\" && amount > 100' (see README)",
+	}
+
 	cacheFlag = &cli.StringFlag{
 		Name:    cacheFlagKey,
 		Aliases: []string{"c"},
@@ -63,8 +94,8 @@ var (
 	workersFlag = &cli.Uint64Flag{
 		Name:    workersFlagKey,
 		Aliases: []string{"w"},
-		Usage:   "amount of workers for parallel block fetch",
-		Value:   3,
+		Usage:   "amount of parallel block fetch requests to every RPC endpoint",
+		Value:   chain.DefaultEndpointWorkers,
 	}
 
 	disableProgressBarFlag = &cli.BoolFlag{
@@ -78,47 +109,131 @@ var (
 		Usage:   "duration limit between block timestamps",
 		Value:   20 * time.Second,
 	}
+
+	networkFlag = &cli.Uint64Flag{
+		Name:    networkFlagKey,
+		Aliases: []string{"m"},
+		Usage:   "network magic of the cache",
+	}
+
+	offlineFlag = &cli.BoolFlag{
+		Name:  offlineFlagKey,
+		Usage: "work only with cached blocks without RPC node, select the cache with -m flag or specify cache file with -c flag",
+	}
+
+	followFlag = &cli.BoolFlag{
+		Name:  followFlagKey,
+		Usage: "keep searching notifications in new blocks after the latest block, can not be used with --to",
+	}
+
+	verifyFlag = &cli.BoolFlag{
+		Name:  verifyFlagKey,
+		Usage: "verify hashes, merkle roots and signatures of fetched blocks",
+	}
+
+	timeoutFlag = &cli.DurationFlag{
+		Name:  timeoutFlagKey,
+		Usage: "timeout of every RPC request",
+		Value: 10 * time.Second,
+	}
+
+	retriesFlag = &cli.Uint64Flag{
+		Name:  retriesFlagKey,
+		Usage: "amount of retries of failed RPC request, requests for unknown blocks or transactions are not retried",
+		Value: 5,
+	}
+
+	retryDelayFlag = &cli.DurationFlag{
+		Name:  retryDelayFlagKey,
+		Usage: "delay before the first retry of failed RPC request, it doubles with every next retry",
+		Value: chain.DefaultRetryDelay,
+	}
+
+	rpsFlag = &cli.Float64Flag{
+		Name:  rpsFlagKey,
+		Usage: "limit of RPC requests per second to all endpoints (default: unlimited)",
+	}
+
+	disableCompressionFlag = &cli.BoolFlag{
+		Name:  disableFlagKey,
+		Usage: "store blocks and application logs without compression",
+	}
+
+	lockTimeoutFlag = &cli.DurationFlag{
+		Name:  lockTimeoutFlagKey,
+		Usage: "time to wait for the cache locked by another monza process, then search without caching, zero waits until released",
+		Value: 10 * time.Second,
+	}
+
+	bulkFlag = &cli.BoolFlag{
+		Name:  bulkFlagKey,
+		Usage: "buffer fetched blocks and write them to the cache in large transactions",
+	}
+
+	noSyncFlag = &cli.BoolFlag{
+		Name:  noSyncFlagKey,
+		Usage: "sync the cache to disk only when all blocks are fetched in --bulk mode, system crash may corrupt the cache",
+	}
+
+	archiveFlag = &cli.BoolFlag{
+		Name:  archiveFlagKey,
+		Usage: "export blocks with application logs and chain metadata instead of neo-go chain dump",
+	}
+
+	cacheFromFlag = &cli.Uint64Flag{
+		Name:  fromFlagKey,
+		Usage: "starting block of the range (default: the first cached block)",
+	}
+
+	cacheToFlag = &cli.Uint64Flag{
+		Name:  toFlagKey,
+		Usage: "ending block of the range, not included (default: the last cached block)",
+	}
 )
 
-func parseNotifications(notifications []string, cli *rpcclient.Client) (map[string]*util.Uint160, error) {
-	res := make(map[string]*util.Uint160, len(notifications))
+// parseNotifications returns matcher of 'notification:contract' patterns or
+// nil if there are no patterns. Patterns with '!' prefix exclude matched
+// notifications.
+func parseNotifications(ctx context.Context, notifications []string, book *addressBook) (*notificationMatcher, error) {
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+
+	res := new(notificationMatcher)
 
 	for _, n := range notifications {
-		pair := strings.Split(n, ":")
-		if len(pair) != 2 {
+		exclude := strings.HasPrefix(n, "!")
+
+		pair := strings.Split(strings.TrimPrefix(n, "!"), ":")
+		if len(pair) != 2 || len(pair[0]) == 0 {
 			return nil, fmt.Errorf("invalid notification %s", n)
 		}
 
-		name := pair[0]
+		if _, err := path.Match(pair[0], ""); err != nil {
+			return nil, fmt.Errorf("invalid notification name pattern %s", pair[0])
+		}
 
-		switch contractName := strings.ToLower(pair[1]); contractName {
-		case "*":
-			res[name] = nil
-		case "gas":
-			u160, err := cli.GetNativeContractHash(nativenames.Gas)
-			if err != nil {
-				return nil, fmt.Errorf("invalid contract name %s", contractName)
-			}
-			res[name] = &u160
-		case "neo":
-			u160, err := cli.GetNativeContractHash(nativenames.Neo)
-			if err != nil {
-				return nil, fmt.Errorf("invalid contract name %s", contractName)
-			}
-			res[name] = &u160
-		default:
-			u160, err := util.Uint160DecodeStringLE(contractName)
+		pattern := notificationPattern{name: pair[0]}
+
+		if pair[1] != "*" {
+			u160, err := book.resolve(ctx, pair[1])
 			if err != nil {
-				return nil, fmt.Errorf("invalid contract name %s", contractName)
+				return nil, err
 			}
-			res[name] = &u160
+			pattern.contract = &u160
+		}
+
+		if exclude {
+			res.exclude = append(res.exclude, pattern)
+		} else {
+			res.include = append(res.include, pattern)
 		}
 	}
 
 	return res, nil
 }
 
-func parseInterval(fromStr, toStr string, cli *rpcclient.Client) (from, to uint32, err error) {
+func parseInterval(ctx context.Context, fromStr, toStr string, blockchain *chain.Chain) (from, to uint32, err error) {
 	switch { // parse from value and return result if it is relative
 	case len(fromStr) == 0:
 		return 0, 0, ErrInvalidInterval(fromStr, toStr)
@@ -127,7 +242,7 @@ func parseInterval(fromStr, toStr string, cli *rpcclient.Client) (from, to uint3
 		if err != nil || v <= 0 {
 			return 0, 0, ErrInvalidInterval(fromStr, toStr)
 		}
-		h, err := cli.GetBlockCount()
+		h, err := blockchain.BlockCount(ctx)
 		if err != nil {
 			return 0, 0, fmt.Errorf("latest block index unavailable: %w", err)
 		}
@@ -145,7 +260,7 @@ func parseInterval(fromStr, toStr string, cli *rpcclient.Client) (from, to uint3
 
 	switch { // parse to value
 	case len(toStr) == 0:
-		h, err := cli.GetBlockCount()
+		h, err := blockchain.BlockCount(ctx)
 		if err != nil {
 			return 0, 0, fmt.Errorf("latest block index unavailable: %w", err)
 		}
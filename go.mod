@@ -1,9 +1,10 @@
 module github.com/alexvanin/monza
 
-go 1.17
+go 1.22
 
 require (
 	github.com/gdamore/tcell/v2 v2.5.1
+	github.com/klauspost/compress v1.18.0
 	github.com/nspcc-dev/neo-go v0.99.2
 	github.com/nspcc-dev/neofs-api-go/v2 v2.11.1
 	github.com/rivo/tview v0.0.0-20220307222120-9994674d60a8
@@ -8,10 +8,12 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
 	"github.com/schollz/progressbar/v3"
 	"github.com/urfave/cli/v2"
 
@@ -30,11 +32,25 @@ func main() {
 				Action:    monza,
 				Flags: []cli.Flag{
 					endpointFlag,
+					timeoutFlag,
+					retriesFlag,
+					retryDelayFlag,
+					rpsFlag,
+					offlineFlag,
+					networkFlag,
 					fromFlag,
 					toFlag,
+					followFlag,
 					notificationFlag,
+					filterFlag,
+					aliasesFlag,
+					addrFormatFlag,
 					cacheFlag,
+					lockTimeoutFlag,
 					workersFlag,
+					bulkFlag,
+					noSyncFlag,
+					verifyFlag,
 					disableProgressBarFlag,
 				},
 			},
@@ -45,11 +61,21 @@ func main() {
 				Action:    stutter,
 				Flags: []cli.Flag{
 					endpointFlag,
+					timeoutFlag,
+					retriesFlag,
+					retryDelayFlag,
+					rpsFlag,
+					offlineFlag,
+					networkFlag,
 					fromFlag,
 					toFlag,
 					stutterThresholdFlag,
 					cacheFlag,
+					lockTimeoutFlag,
 					workersFlag,
+					bulkFlag,
+					noSyncFlag,
+					verifyFlag,
 					disableProgressBarFlag,
 				},
 			},
@@ -60,7 +86,157 @@ func main() {
 				Action:    explorer,
 				Flags: []cli.Flag{
 					endpointFlag,
+					timeoutFlag,
+					retriesFlag,
+					retryDelayFlag,
+					rpsFlag,
+					offlineFlag,
+					networkFlag,
 					cacheFlag,
+					aliasesFlag,
+					addrFormatFlag,
+					lockTimeoutFlag,
+					verifyFlag,
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "manage blockchain caches",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "list",
+						Usage:     "show cached networks with their size and block ranges",
+						UsageText: "monza cache list",
+						Action:    cacheList,
+						Flags: []cli.Flag{
+							cacheFlag,
+						},
+					},
+					{
+						Name:      "delete",
+						Usage:     "delete the whole network cache or a range of blocks in it",
+						UsageText: "monza cache delete -m 860833102 --from 101000 --to 102000",
+						Action:    cacheDelete,
+						Flags: []cli.Flag{
+							networkFlag,
+							cacheFromFlag,
+							cacheToFlag,
+							cacheFlag,
+						},
+					},
+					{
+						Name:      "compact",
+						Usage:     "reclaim unused space of the network cache",
+						UsageText: "monza cache compact -m 860833102",
+						Action:    cacheCompact,
+						Flags: []cli.Flag{
+							networkFlag,
+							cacheFlag,
+						},
+					},
+					{
+						Name:      "ranges",
+						Usage:     "show contiguous ranges of cached blocks and blocks without application logs",
+						UsageText: "monza cache ranges -m 860833102",
+						Action:    cacheRanges,
+						Flags: []cli.Flag{
+							networkFlag,
+							cacheFlag,
+						},
+					},
+					{
+						Name:      "fill",
+						Usage:     "fetch missing blocks and application logs of the range",
+						UsageText: "monza cache fill -r [endpoint] --from 101000 --to p1000",
+						Action:    cacheFill,
+						Flags: []cli.Flag{
+							endpointFlag,
+							timeoutFlag,
+							retriesFlag,
+							retryDelayFlag,
+							rpsFlag,
+							fromFlag,
+							toFlag,
+							cacheFlag,
+							lockTimeoutFlag,
+							workersFlag,
+							bulkFlag,
+							noSyncFlag,
+							verifyFlag,
+							disableProgressBarFlag,
+						},
+					},
+					{
+						Name:      "export",
+						Usage:     "export cached blocks as neo-go chain dump or the cache archive with application logs",
+						UsageText: "monza cache export -m 860833102 --from 101000 --to 102000 [--archive] file",
+						Action:    cacheExport,
+						Flags: []cli.Flag{
+							networkFlag,
+							cacheFromFlag,
+							cacheToFlag,
+							cacheFlag,
+							archiveFlag,
+							disableProgressBarFlag,
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "import blocks from the chain dump of neo-go node or the cache archive",
+						UsageText: "monza cache import -r [endpoint] chain.acc\n   monza cache import cache.tar.gz",
+						Action:    cacheImport,
+						Flags: []cli.Flag{
+							endpointFlag,
+							timeoutFlag,
+							retriesFlag,
+							retryDelayFlag,
+							rpsFlag,
+							cacheFlag,
+							lockTimeoutFlag,
+							disableProgressBarFlag,
+						},
+					},
+					{
+						Name:      "compress",
+						Usage:     "compress blocks and application logs of the network cache with zstd",
+						UsageText: "monza cache compress -m 860833102 [--disable]",
+						Action:    cacheCompress,
+						Flags: []cli.Flag{
+							networkFlag,
+							cacheFlag,
+							disableCompressionFlag,
+							disableProgressBarFlag,
+						},
+					},
+					{
+						Name:      "index",
+						Usage:     "rebuild notification index of the network cache",
+						UsageText: "monza cache index -m 860833102",
+						Action:    cacheIndex,
+						Flags: []cli.Flag{
+							networkFlag,
+							cacheFlag,
+							disableProgressBarFlag,
+						},
+					},
+					{
+						Name:      "verify",
+						Usage:     "verify cached blocks of the range and remove invalid ones",
+						UsageText: "monza cache verify -r [endpoint] --from 101000 --to p1000",
+						Action:    cacheVerify,
+						Flags: []cli.Flag{
+							endpointFlag,
+							timeoutFlag,
+							retriesFlag,
+							retryDelayFlag,
+							rpsFlag,
+							fromFlag,
+							toFlag,
+							cacheFlag,
+							lockTimeoutFlag,
+							disableProgressBarFlag,
+						},
+					},
 				},
 			},
 		},
@@ -74,169 +250,477 @@ func main() {
 }
 
 func monza(c *cli.Context) (err error) {
+	if c.Bool(followFlagKey) && (c.IsSet(toFlagKey) || c.Bool(offlineFlagKey)) {
+		return errors.New("--follow flag can not be used with --to flag or in --offline mode")
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 
 	// parse blockchain info
-	cacheDir := c.String(cacheFlagKey)
-	if len(cacheDir) == 0 {
-		cacheDir, err = defaultConfigDir()
-		if err != nil {
-			return err
-		}
-	}
-
-	blockchain, err := chain.Open(ctx, cacheDir, c.String(endpointFlagKey))
+	blockchain, err := openChain(ctx, c, chainOptions(c))
 	if err != nil {
-		return fmt.Errorf("cannot initialize remote blockchain client: %w", err)
+		return err
 	}
 	defer func() {
-		blockchain.Close()
+		closeChain(blockchain)
 		cancel()
 	}()
 
 	// parse block indices
-	from, to, err := parseInterval(c.String(fromFlagKey), c.String(toFlagKey), blockchain.Client)
+	from, to, err := parseInterval(ctx, c.String(fromFlagKey), c.String(toFlagKey), blockchain)
+	if err != nil {
+		return err
+	}
+
+	book, err := openAddressBook(ctx, c, blockchain)
 	if err != nil {
 		return err
 	}
 
 	// parse notifications
-	notifications, err := parseNotifications(c.StringSlice(notificationFlagKey), blockchain.Client)
+	notifications, err := parseNotifications(ctx, c.StringSlice(notificationFlagKey), book)
 	if err != nil {
 		return err
 	}
 
+	filter, err := parseFilter(ctx, c.String(filterFlagKey), blockchain, book)
+	if err != nil {
+		return err
+	}
+
+	if notifications == nil {
+		if filter == nil {
+			return errors.New("specify notifications with -n flag or --filter expression")
+		}
+		// notifications selected by the filter are searched in the index
+		notifications = filter.notifications()
+	}
+
 	// start monza
-	return run(ctx, &params{
+	p := &params{
 		from:          from,
 		to:            to,
 		blockchain:    blockchain,
 		notifications: notifications,
-		workers:       int(c.Uint64(workersFlagKey)),
+		filter:        filter,
+		book:          book,
+		workers:       blockchain.Workers(),
 		disableBar:    c.Bool(disableProgressBarFlagKey),
-	})
+	}
+
+	err = run(ctx, p)
+	if err != nil || !c.Bool(followFlagKey) {
+		return err
+	}
+
+	return follow(ctx, p)
 }
 
 type params struct {
-	from, to      uint32
-	blockchain    *chain.Chain
-	notifications map[string]*util.Uint160
-	workers       int
-	disableBar    bool
+	from, to   uint32
+	blockchain *chain.Chain
+	// notifications are patterns of names and contracts, all notifications
+	// are matched if it is nil
+	notifications *notificationMatcher
+	// filter selects notifications matched by notification patterns
+	filter *filter
+	// book names contracts and accounts in the output
+	book       *addressBook
+	workers    int
+	disableBar bool
+
+	// headers makes workers fetch only headers of blocks
+	headers bool
+
+	// indexed ranges of blocks are searched with the notification index,
+	// hits contain found notifications of these blocks
+	indexed []chain.Range
+	hits    map[uint32][]chain.IndexedNotification
+}
+
+// runQueueFactor defines how many blocks per worker may be fetched ahead of
+// the block which is being printed.
+const runQueueFactor = 64
+
+// searchJob is a block to search notifications in. Result of the search is
+// sent to res channel.
+type searchJob struct {
+	index uint32
+	res   chan<- searchResult
+}
+
+// searchResult contains block and its notifications matched by the search.
+type searchResult struct {
+	block  *block.Block
+	events []chain.Event
+	err    error
 }
 
+// run searches notifications with a pipeline: blocks are fetched and matched
+// by parallel workers, and results are printed in block order as soon as
+// they are ready.
 func run(ctx context.Context, p *params) error {
-	err := cacheBlocks(ctx, p)
+	if p.workers <= 0 {
+		return fmt.Errorf("invalid amount of workers %d", p.workers)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	err := p.findIndexed(ctx)
 	if err != nil {
 		return err
 	}
 
-	for i := p.from; i < p.to; i++ {
-		b, err := p.blockchain.Block(i)
-		if err != nil {
-			return fmt.Errorf("cannot fetch block %d: %w", i, err)
+	var bar *progressbar.ProgressBar
+	if !p.disableBar {
+		bar = newProgressBar(int(p.to-p.from), "syncing", "blocks")
+	}
+
+	// queue keeps result channels in block order
+	queue := make(chan chan searchResult, p.workers*runQueueFactor)
+	jobCh := make(chan searchJob)
+
+	go func() {
+		defer close(queue)
+		defer close(jobCh)
+
+		for i := p.from; i < p.to; i++ {
+			res := make(chan searchResult, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case queue <- res:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobCh <- searchJob{index: i, res: res}:
+			}
 		}
+	}()
 
-		notifications, err := p.blockchain.AllNotifications(b)
-		if err != nil {
-			return fmt.Errorf("cannot fetch notifications from block %d: %w", i, err)
+	workers := new(sync.WaitGroup)
+	defer func() {
+		cancel()
+		// producer stops on cancel and closes channels, so the cache is
+		// not closed while workers still search blocks
+		for range queue {
+		}
+		workers.Wait()
+	}()
+
+	for i := 0; i < p.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobCh {
+				job.res <- p.search(ctx, job.index)
+			}
+		}()
+	}
+
+	for res := range queue {
+		var r searchResult
+		select {
+		case <-ctx.Done():
+			return errors.New("interrupted")
+		case r = <-res:
+		}
+
+		if r.err != nil {
+			return r.err
+		}
+
+		if bar != nil {
+			if len(r.events) != 0 {
+				_ = bar.Clear()
+			}
+			_ = bar.Add(1)
 		}
 
-		for _, ev := range notifications {
-			contract, ok := p.notifications[ev.Name]
-			if !ok {
-				continue
+		for _, ev := range r.events {
+			printNotification(r.block, ev.NotificationEvent, p.book)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return errors.New("interrupted")
+	}
+
+	return nil
+}
+
+// findIndexed looks up notifications in blocks of the notification index.
+func (p *params) findIndexed(ctx context.Context) error {
+	var err error
+
+	p.indexed, err = p.blockchain.IndexedRanges(ctx, p.from, p.to)
+	if err != nil {
+		return err
+	}
+
+	p.hits = make(map[uint32][]chain.IndexedNotification)
+	if p.notifications == nil || !p.notifications.indexed() {
+		// index is searched by notification names
+		p.indexed = nil
+		return nil
+	}
+
+	// names of the index are matched with glob patterns
+	var names []string
+
+	for _, pattern := range p.notifications.include {
+		lookup := []string{pattern.name}
+		if pattern.glob() {
+			if names == nil {
+				if names, err = p.blockchain.IndexedNames(ctx); err != nil {
+					return err
+				}
+			}
+
+			lookup = lookup[:0]
+			for _, name := range names {
+				if pattern.matchName(name) {
+					lookup = append(lookup, name)
+				}
 			}
+		}
 
-			if contract != nil && !contract.Equals(ev.ScriptHash) {
-				continue
+		for _, name := range lookup {
+			found, err := p.blockchain.FindNotifications(ctx, name, pattern.contract, p.indexed)
+			if err != nil {
+				return err
 			}
+			for _, n := range found {
+				p.hits[n.Block] = append(p.hits[n.Block], n)
+			}
+		}
+	}
+
+	for i, hits := range p.hits {
+		sort.Slice(hits, func(i, j int) bool {
+			return hits[i].Position < hits[j].Position
+		})
 
-			switch ev.Name {
-			case "Transfer":
-				PrintTransfer(b, ev)
-			case "NewEpoch":
-				PrintNewEpoch(b, ev)
-			case "AddPeer":
-				PrintAddPeer(b, ev)
-			case "UpdateState":
-				PrintUpdateState(b, ev)
-			default:
-				PrintEvent(b, ev, "")
+		// notification may match several patterns
+		unique := hits[:0]
+		for k, n := range hits {
+			if k == 0 || n.Position != hits[k-1].Position {
+				unique = append(unique, n)
 			}
 		}
+		p.hits[i] = unique
 	}
 
 	return nil
 }
 
+func (p *params) isIndexed(i uint32) bool {
+	k := sort.Search(len(p.indexed), func(k int) bool {
+		return p.indexed[k].Last >= i
+	})
+
+	return k < len(p.indexed) && p.indexed[k].First <= i
+}
+
+// search fetches the block and returns its notifications matched by the
+// search parameters. Only blocks with found notifications are fetched in
+// indexed ranges.
+func (p *params) search(ctx context.Context, i uint32) searchResult {
+	if p.isIndexed(i) {
+		return p.searchIndexed(ctx, i)
+	}
+
+	b, err := p.blockchain.Block(ctx, i)
+	if err != nil {
+		return searchResult{err: fmt.Errorf("cannot fetch block %d: %w", i, err)}
+	}
+
+	events, err := p.blockchain.BlockEvents(ctx, b)
+	if err != nil {
+		return searchResult{err: fmt.Errorf("cannot fetch notifications from block %d: %w", i, err)}
+	}
+
+	res := searchResult{block: b}
+	for _, ev := range events {
+		ok, err := p.match(ctx, b, ev)
+		if err != nil {
+			return searchResult{err: err}
+		}
+
+		if ok {
+			res.events = append(res.events, ev)
+		}
+	}
+
+	return res
+}
+
+func (p *params) searchIndexed(ctx context.Context, i uint32) searchResult {
+	hits := p.hits[i]
+	if len(hits) == 0 {
+		return searchResult{}
+	}
+
+	b, err := p.blockchain.Block(ctx, i)
+	if err != nil {
+		return searchResult{err: fmt.Errorf("cannot fetch block %d: %w", i, err)}
+	}
+
+	events, err := p.blockchain.NotificationEvents(ctx, hits)
+	if err != nil {
+		return searchResult{err: fmt.Errorf("cannot fetch notifications from block %d: %w", i, err)}
+	}
+
+	res := searchResult{block: b}
+	for _, ev := range events {
+		// excluded notifications are found in the index too
+		ok, err := p.match(ctx, b, ev)
+		if err != nil {
+			return searchResult{err: err}
+		}
+
+		if ok {
+			res.events = append(res.events, ev)
+		}
+	}
+
+	return res
+}
+
+// match returns true if the event is selected by notification patterns and
+// the filter.
+func (p *params) match(ctx context.Context, b *block.Block, ev chain.Event) (bool, error) {
+	if p.notifications != nil && !p.notifications.match(ev.Name, ev.ScriptHash) {
+		return false, nil
+	}
+
+	if p.filter == nil {
+		return true, nil
+	}
+
+	return p.filter.match(ctx, b, ev)
+}
+
+// follow searches notifications in new blocks as they are produced until
+// monza is interrupted.
+func follow(ctx context.Context, p *params) error {
+	err := p.blockchain.Follow(ctx, p.to, func(b *block.Block) error {
+		r := p.search(ctx, b.Index)
+		if r.err != nil {
+			return r.err
+		}
+
+		for _, ev := range r.events {
+			printNotification(r.block, ev.NotificationEvent, p.book)
+		}
+
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+func printNotification(b *block.Block, ev state.NotificationEvent, book *addressBook) {
+	switch ev.Name {
+	case "Transfer":
+		PrintTransfer(b, ev, book)
+	case "NewEpoch":
+		PrintNewEpoch(b, ev)
+	case "AddPeer":
+		PrintAddPeer(b, ev)
+	case "UpdateState":
+		PrintUpdateState(b, ev)
+	default:
+		PrintEvent(b, ev, "")
+	}
+}
+
 func cacheBlocks(ctx context.Context, p *params) error {
+	indices := make([]uint32, 0, p.to-p.from)
+	for i := p.from; i < p.to; i++ {
+		indices = append(indices, i)
+	}
+
+	return fetchBlocks(ctx, p, indices)
+}
+
+// fetch caches the block and application logs of its transactions or only
+// the header of the block.
+func (p *params) fetch(ctx context.Context, i uint32) error {
+	if p.headers {
+		_, err := p.blockchain.Header(ctx, i)
+		return err
+	}
+
+	b, err := p.blockchain.Block(ctx, i)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.blockchain.AllNotifications(ctx, b)
+	return err
+}
+
+// fetchBlocks caches specified blocks and application logs of their
+// transactions with a pool of parallel workers.
+func fetchBlocks(ctx context.Context, p *params, indices []uint32) (err error) {
 	if p.workers <= 0 {
 		return fmt.Errorf("invalid amount of workers %d", p.workers)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	workers := new(sync.WaitGroup)
+	defer func() {
+		cancel()
+		workers.Wait()
+	}()
+
 	var bar *progressbar.ProgressBar
 	if !p.disableBar {
-		bar = progressbar.NewOptions(int(p.to-p.from),
-			progressbar.OptionSetDescription("syncing"),
-			progressbar.OptionSetWriter(os.Stderr),
-			progressbar.OptionSetWidth(10),
-			progressbar.OptionThrottle(65*time.Millisecond),
-			progressbar.OptionShowCount(),
-			progressbar.OptionShowIts(),
-			progressbar.OptionSetItsString("blocks"),
-			progressbar.OptionOnCompletion(func() {
-				fmt.Fprint(os.Stderr, "\n")
-			}),
-			progressbar.OptionSpinnerType(14),
-			progressbar.OptionSetWidth(50),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        "#",
-				SaucerHead:    "#",
-				SaucerPadding: " ",
-				BarStart:      "[",
-				BarEnd:        "]",
-			}),
-		)
+		unit := "blocks"
+		if p.headers {
+			unit = "headers"
+		}
+		bar = newProgressBar(len(indices), "syncing", unit)
 	}
 
 	jobCh := make(chan uint32)
 	errCh := make(chan error)
-	wgCh := make(chan struct{})
-
-	wg := new(sync.WaitGroup)
 
 	for i := 0; i < p.workers; i++ {
+		workers.Add(1)
 		go func(ctx context.Context, ch <-chan uint32, out chan<- error) {
+			defer workers.Done()
 			for {
 				select {
 				case <-ctx.Done():
 					return
 				case block, ok := <-ch:
-					wg.Add(1)
 					if !ok {
 						return
 					}
-					b, err := p.blockchain.Block(block)
-					if err != nil {
-						out <- err
-						return
-					}
-					_, err = p.blockchain.AllNotifications(b)
+					err := p.fetch(ctx, block)
 					if err != nil {
-						out <- err
+						select {
+						case out <- err:
+						case <-ctx.Done():
+						}
 						return
 					}
 					if bar != nil {
 						bar.Add(1)
 					}
-					wg.Done()
 				}
 			}
 		}(ctx, jobCh, errCh)
 	}
 
-	for i := p.from; i < p.to; i++ {
+	for _, i := range indices {
 		select {
 		case <-ctx.Done():
 			return errors.New("interrupted")
@@ -246,9 +730,13 @@ func cacheBlocks(ctx context.Context, p *params) error {
 		}
 	}
 
+	// workers finish received jobs and stop
+	close(jobCh)
+
+	doneCh := make(chan struct{})
 	go func() {
-		wg.Wait()
-		close(wgCh)
+		workers.Wait()
+		close(doneCh)
 	}()
 
 	select {
@@ -256,11 +744,149 @@ func cacheBlocks(ctx context.Context, p *params) error {
 		return errors.New("interrupted")
 	case err := <-errCh:
 		return err
-	case <-wgCh:
+	case <-doneCh:
 		return nil
 	}
 }
 
+func newProgressBar(max int, description, items string) *progressbar.ProgressBar {
+	return progressbar.NewOptions(max,
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetItsString(items),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetWidth(50),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "#",
+			SaucerHead:    "#",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+}
+
+// chainOptions returns options of the chain based on command flags.
+func chainOptions(c *cli.Context) chain.Options {
+	opts := chain.Options{
+		Verify:            c.Bool(verifyFlagKey),
+		EndpointWorkers:   int(c.Uint64(workersFlagKey)),
+		RequestTimeout:    c.Duration(timeoutFlagKey),
+		Retries:           int(c.Uint64(retriesFlagKey)),
+		RetryDelay:        c.Duration(retryDelayFlagKey),
+		RequestsPerSecond: c.Float64(rpsFlagKey),
+		LockTimeout:       c.Duration(lockTimeoutFlagKey),
+		NoCacheOnLock:     true,
+		Bulk:              c.Bool(bulkFlagKey),
+		NoSync:            c.Bool(noSyncFlagKey),
+		CacheLocked: func(dbPath string) {
+			fmt.Fprintf(os.Stderr, "cache %s is locked by another process, waiting\n", dbPath)
+		},
+	}
+
+	if !c.Bool(disableProgressBarFlagKey) {
+		opts.MigrationProgress = newMigrationProgress("migrating")
+	}
+
+	return opts
+}
+
+// newMigrationProgress returns progress handler showing progress bar for
+// every processed bucket.
+func newMigrationProgress(description string) chain.MigrationProgress {
+	var (
+		name string
+		bar  *progressbar.ProgressBar
+	)
+
+	return func(n string, done, total int) {
+		if bar == nil || name != n {
+			name, bar = n, newProgressBar(total, description+" "+n, "records")
+		}
+		_ = bar.Set(done)
+	}
+}
+
+// openChain opens the blockchain with RPC node or only the cache in
+// offline mode.
+func openChain(ctx context.Context, c *cli.Context, opts chain.Options) (*chain.Chain, error) {
+	if c.Bool(offlineFlagKey) {
+		dbPath, err := offlineCachePath(c)
+		if err != nil {
+			return nil, err
+		}
+
+		blockchain, err := chain.OpenOffline(dbPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open blockchain cache: %w", err)
+		}
+
+		return blockchain, nil
+	}
+
+	endpoints := c.StringSlice(endpointFlagKey)
+	if len(endpoints) == 0 {
+		return nil, errors.New("rpc endpoint is not specified, use -r flag or --offline mode")
+	}
+
+	cacheDir, err := parseCacheDir(c)
+	if err != nil {
+		return nil, err
+	}
+
+	blockchain, err := chain.Open(ctx, cacheDir, endpoints, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize remote blockchain client: %w", err)
+	}
+
+	if blockchain.CacheFile() == "" {
+		fmt.Fprintln(os.Stderr, "cache is still locked, caching is disabled")
+	}
+
+	return blockchain, nil
+}
+
+// closeChain closes the blockchain and reports RPC endpoints that failed
+// any request. Statistics of every endpoint is reported if several
+// endpoints are used.
+func closeChain(blockchain *chain.Chain) {
+	stats := blockchain.Endpoints()
+	for _, stat := range stats {
+		if stat.Failures == 0 && len(stats) == 1 {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "endpoint:%s requests:%d failures:%d latency:%s",
+			stat.Address, stat.Requests, stat.Failures, stat.Latency.Round(time.Millisecond))
+		if stat.Err != nil {
+			fmt.Fprintf(os.Stderr, " last error:%s", stat.Err)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	// Close drops the error of the last write in bulk ingest mode
+	if err := blockchain.FinishBulk(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write buffered blocks to the cache: %v\n", err)
+	}
+
+	blockchain.Close()
+}
+
+func parseCacheDir(c *cli.Context) (string, error) {
+	dir := c.String(cacheFlagKey)
+	if len(dir) != 0 {
+		return dir, nil
+	}
+
+	return defaultConfigDir()
+}
+
 func defaultConfigDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
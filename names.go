@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/urfave/cli/v2"
+
+	"github.com/alexvanin/monza/chain"
+)
+
+// aliasesExt is the extension of alias files in the cache directory, file
+// name is the magic number of the network.
+const aliasesExt = ".aliases.json"
+
+// nnsAlias is the alias of NNS contract, it is used to resolve domain names
+// in chains where NNS is not the first deployed contract.
+const nnsAlias = "nns"
+
+// Formats of script hashes in the output:
+//
+//	address  N3 address
+//	le       little endian hex without prefix, e.g. d2a4cff31913016155e38e474a2c06d08be276cf for GAS
+//	be       0x-prefixed big endian hex in the byte order of stack items,
+//	         e.g. 0xcf76e28bd0062c4a478ee35561011319f3cfa4d2 for GAS
+const (
+	addrFormatAddress = "address"
+	addrFormatLE      = "le"
+	addrFormatBE      = "be"
+)
+
+// addressBook maps names of contracts to their script hashes and back.
+// Names are names of native contracts, aliases of the network and NNS
+// domain names. Names are case-insensitive.
+type addressBook struct {
+	blockchain *chain.Chain
+	// format of script hashes without names
+	format string
+
+	mu     sync.Mutex
+	hashes map[string]util.Uint160
+	names  map[util.Uint160]string
+}
+
+// aliasesPath returns path to the alias file of the network in the cache
+// directory.
+func aliasesPath(dir string, magic uint32) string {
+	return path.Join(dir, strconv.FormatUint(uint64(magic), 10)+aliasesExt)
+}
+
+// openAddressBook returns address book of the chain with aliases from the
+// file specified by the flag or from the alias file of the network in the
+// cache directory if it exists.
+func openAddressBook(ctx context.Context, c *cli.Context, blockchain *chain.Chain) (*addressBook, error) {
+	format := strings.ToLower(c.String(addrFormatFlagKey))
+	switch format {
+	case addrFormatAddress, addrFormatLE, addrFormatBE:
+	default:
+		return nil, fmt.Errorf("invalid address format %s, use '%s', '%s' or '%s'",
+			format, addrFormatAddress, addrFormatLE, addrFormatBE)
+	}
+
+	file := c.String(aliasesFlagKey)
+	if file == "" {
+		dir, err := parseCacheDir(c)
+		if err != nil {
+			return nil, err
+		}
+
+		file = aliasesPath(dir, blockchain.Magic())
+		if _, err = os.Stat(file); err != nil {
+			file = ""
+		}
+	}
+
+	return newAddressBook(ctx, blockchain, file, format)
+}
+
+// newAddressBook returns address book with native contracts of the chain
+// and aliases from the file. Alias file is a JSON object with names as keys
+// and script hashes as values, see parseScriptHash. Alias nns sets NNS
+// contract of the chain.
+func newAddressBook(ctx context.Context, blockchain *chain.Chain, aliasesFile, format string) (*addressBook, error) {
+	a := &addressBook{
+		blockchain: blockchain,
+		format:     format,
+		hashes:     make(map[string]util.Uint160),
+		names:      make(map[util.Uint160]string),
+	}
+
+	natives, err := blockchain.NativeContracts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, h := range natives {
+		a.add(name, h)
+		// short names such as 'gas' and 'management' are printed
+		a.add(nativeShortName(name), h)
+	}
+
+	if aliasesFile == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(aliasesFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read alias file: %w", err)
+	}
+
+	var aliases map[string]string
+	if err = json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("cannot parse alias file %s: %w", aliasesFile, err)
+	}
+
+	for name, s := range aliases {
+		h, err := parseScriptHash(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script hash of alias %s in %s", name, aliasesFile)
+		}
+		// aliases override names of native contracts
+		a.add(name, h)
+
+		if strings.EqualFold(name, nnsAlias) {
+			blockchain.SetNNSContract(h)
+		}
+	}
+
+	return a, nil
+}
+
+// nativeShortName returns the name of native contract without Token and
+// Contract words, e.g. 'gas' for GasToken.
+func nativeShortName(name string) string {
+	short := strings.ToLower(name)
+	short = strings.TrimSuffix(short, "token")
+	short = strings.TrimSuffix(short, "contract")
+	short = strings.TrimPrefix(short, "contract")
+
+	return short
+}
+
+// add stores the name of the script hash, the last added name is printed.
+func (a *addressBook) add(name string, h util.Uint160) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.hashes[strings.ToLower(name)] = h
+	a.names[h] = name
+}
+
+// parseScriptHash returns script hash specified in one of output formats:
+// N3 address, LE script hash or 0x-prefixed BE script hash.
+func parseScriptHash(s string) (util.Uint160, error) {
+	const uint160Len = 2 * util.Uint160Size
+
+	switch {
+	case len(s) == uint160Len+2 && strings.HasPrefix(s, "0x"):
+		return util.Uint160DecodeStringBE(s[2:])
+	case len(s) == uint160Len:
+		return util.Uint160DecodeStringLE(s)
+	default:
+		return address.StringToUint160(s)
+	}
+}
+
+// formatScriptHash returns script hash in the format of the output.
+func formatScriptHash(h util.Uint160, format string) string {
+	switch format {
+	case addrFormatAddress:
+		return address.Uint160ToString(h)
+	case addrFormatBE:
+		return "0x" + h.StringBE()
+	default:
+		return h.StringLE()
+	}
+}
+
+// resolve returns script hash of the contract or the account specified by
+// the script hash, see parseScriptHash, name of the native contract, alias
+// or NNS domain name.
+func (a *addressBook) resolve(ctx context.Context, s string) (util.Uint160, error) {
+	if h, err := parseScriptHash(s); err == nil {
+		return h, nil
+	}
+
+	a.mu.Lock()
+	h, ok := a.hashes[strings.ToLower(s)]
+	a.mu.Unlock()
+
+	if ok {
+		return h, nil
+	}
+
+	if !strings.Contains(s, ".") {
+		return util.Uint160{}, fmt.Errorf("invalid contract name %s", s)
+	}
+
+	h, err := a.blockchain.ResolveNNS(ctx, s)
+	if errors.Is(err, chain.ErrUnknownNNS) {
+		return util.Uint160{}, fmt.Errorf("cannot resolve NNS name %s: %w, set its script hash with '%s' alias",
+			s, err, nnsAlias)
+	}
+	if err != nil {
+		return util.Uint160{}, err
+	}
+
+	a.add(s, h)
+
+	return h, nil
+}
+
+// name returns the name of the script hash or the script hash in the
+// format of the address book if it has no name.
+func (a *addressBook) name(h util.Uint160) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if name, ok := a.names[h]; ok {
+		return name
+	}
+
+	return formatScriptHash(h, a.format)
+}
+
+// account returns the name of the account script hash, see name. Byte
+// strings of other length are returned as LE hex.
+func (a *addressBook) account(data []byte) string {
+	h, err := util.Uint160DecodeBytesBE(data)
+	if err != nil {
+		return hex.EncodeToString(revertBytes(append([]byte{}, data...)))
+	}
+
+	return a.name(h)
+}
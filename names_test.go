@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+func TestScriptHashFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		s      string
+	}{
+		{addrFormatAddress, "NepwUjd9GhqgNkrfXaxj9mmsFhFzGoFuWM"},
+		{addrFormatLE, "d2a4cff31913016155e38e474a2c06d08be276cf"},
+		{addrFormatBE, "0xcf76e28bd0062c4a478ee35561011319f3cfa4d2"},
+	}
+
+	for _, tc := range tests {
+		if s := formatScriptHash(testGas, tc.format); s != tc.s {
+			t.Errorf("%s: expected %s, got %s", tc.format, tc.s, s)
+		}
+
+		h, err := parseScriptHash(tc.s)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+
+		if h != testGas {
+			t.Errorf("%s: expected %s, got %s", tc.format, testGas.StringLE(), h.StringLE())
+		}
+	}
+
+	for _, s := range []string{"0xd2a4cff31913016155e38e474a2c06d08be276", "d2a4cff3", "NepwUjd9GhqgNkrfXaxj9mmsFhFzGoFuW"} {
+		if _, err := parseScriptHash(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+
+	// script hash in LE with 0x prefix is parsed as BE
+	h, err := parseScriptHash("0x" + testGas.StringLE())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != (util.Uint160{0xd2, 0xa4, 0xcf, 0xf3, 0x19, 0x13, 0x01, 0x61, 0x55, 0xe3, 0x8e, 0x47, 0x4a, 0x2c, 0x06, 0xd0, 0x8b, 0xe2, 0x76, 0xcf}) {
+		t.Errorf("unexpected script hash %s", h.StringLE())
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// notificationPattern matches notifications by name and contract. Name is
+// a pattern of path.Match, nil contract matches every contract.
+type notificationPattern struct {
+	name     string
+	contract *util.Uint160
+}
+
+func (p notificationPattern) match(name string, contract util.Uint160) bool {
+	if p.contract != nil && !p.contract.Equals(contract) {
+		return false
+	}
+
+	return p.matchName(name)
+}
+
+func (p notificationPattern) matchName(name string) bool {
+	ok, _ := path.Match(p.name, name)
+	return ok
+}
+
+// glob returns true if the pattern matches more than one name.
+func (p notificationPattern) glob() bool {
+	return strings.ContainsAny(p.name, `*?[\`)
+}
+
+// notificationMatcher selects notifications which match any included
+// pattern and none of excluded patterns. Every notification is included if
+// there are no included patterns.
+type notificationMatcher struct {
+	include []notificationPattern
+	exclude []notificationPattern
+}
+
+func (m *notificationMatcher) match(name string, contract util.Uint160) bool {
+	for _, p := range m.exclude {
+		if p.match(name, contract) {
+			return false
+		}
+	}
+
+	if len(m.include) == 0 {
+		return true
+	}
+
+	for _, p := range m.include {
+		if p.match(name, contract) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// indexed returns true if included notifications can be searched in the
+// notification index. It is false if every notification is included, so
+// blocks are read anyway.
+func (m *notificationMatcher) indexed() bool {
+	if len(m.include) == 0 {
+		return false
+	}
+
+	for _, p := range m.include {
+		if p.name == "*" && p.contract == nil {
+			return false
+		}
+	}
+
+	return true
+}
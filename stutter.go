@@ -8,7 +8,6 @@ import (
 	"os/signal"
 	"time"
 
-	"github.com/alexvanin/monza/chain"
 	"github.com/nspcc-dev/neo-go/pkg/core/block"
 	"github.com/urfave/cli/v2"
 )
@@ -17,25 +16,17 @@ func stutter(c *cli.Context) (err error) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 
 	// parse blockchain info
-	cacheDir := c.String(cacheFlagKey)
-	if len(cacheDir) == 0 {
-		cacheDir, err = defaultConfigDir()
-		if err != nil {
-			return err
-		}
-	}
-
-	blockchain, err := chain.Open(ctx, cacheDir, c.String(endpointFlagKey))
+	blockchain, err := openChain(ctx, c, chainOptions(c))
 	if err != nil {
-		return fmt.Errorf("cannot initialize remote blockchain client: %w", err)
+		return err
 	}
 	defer func() {
-		blockchain.Close()
+		closeChain(blockchain)
 		cancel()
 	}()
 
 	// parse block indices
-	from, to, err := parseInterval(c.String(fromFlagKey), c.String(toFlagKey), blockchain.Client)
+	from, to, err := parseInterval(ctx, c.String(fromFlagKey), c.String(toFlagKey), blockchain)
 	if err != nil {
 		return err
 	}
@@ -47,13 +38,14 @@ func stutter(c *cli.Context) (err error) {
 		return errors.New("range must contain at least two blocks")
 	}
 
-	// fetch blocks
+	// fetch headers, timestamps are enough to find stutters
 	err = cacheBlocks(ctx, &params{
 		from:       from,
 		to:         to,
 		blockchain: blockchain,
-		workers:    int(c.Uint64(workersFlagKey)),
+		workers:    blockchain.Workers(),
 		disableBar: c.Bool(disableProgressBarFlagKey),
+		headers:    true,
 	})
 	if err != nil {
 		return err
@@ -61,15 +53,15 @@ func stutter(c *cli.Context) (err error) {
 
 	// process blocks one by one
 	var (
-		prev, curr       *block.Block
+		prev, curr       *block.Header
 		prevTS, currTS   time.Time
 		lastStutterBlock uint32
 	)
 
 	for i := from; i < to; i++ {
-		b, err := blockchain.Block(i)
+		b, err := blockchain.Header(ctx, i)
 		if err != nil {
-			return fmt.Errorf("cannot fetch block %d: %w", i, err)
+			return fmt.Errorf("cannot fetch header %d: %w", i, err)
 		}
 
 		prev, prevTS = curr, currTS